@@ -0,0 +1,281 @@
+// Package api implements the JSON API and the status page.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/history"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/rs/zerolog/log"
+)
+
+// Refresher looks up domains and keeps their status.
+type Refresher interface {
+	RefreshDomain(ctx context.Context, name, host string) (client.Result, error)
+	Statuses() []refresher.Status
+	Status(name string) (refresher.Status, bool)
+}
+
+// Historian returns the expiry history of domains.
+type Historian interface {
+	Record(domain string) (history.Record, bool)
+}
+
+// Server serves the API: the domain endpoints are read-only and open, like
+// the status page, while the admin ones require a token.
+type Server struct {
+	mux       *http.ServeMux
+	cache     *client.CachedClient
+	token     string
+	refresher Refresher
+	history   Historian
+}
+
+// Option configures the API server.
+type Option func(*Server)
+
+// WithToken sets the token required to use the admin endpoints, as a bearer
+// token. If no token is set, they are disabled.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithRefresher sets the refresher used to refresh domains. It defaults to
+// looking the domain up in the cache.
+func WithRefresher(refresher Refresher) Option {
+	return func(s *Server) {
+		s.refresher = refresher
+	}
+}
+
+// WithHistory sets the expiry history returned by the API.
+func WithHistory(history Historian) Option {
+	return func(s *Server) {
+		s.history = history
+	}
+}
+
+// New returns the API server for the given cache.
+func New(cache *client.CachedClient, opts ...Option) *Server {
+	s := &Server{
+		mux:   http.NewServeMux(),
+		cache: cache,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("GET /api/v1/cache", s.admin(s.listCache))
+	s.mux.HandleFunc("DELETE /api/v1/cache/{domain}", s.admin(s.deleteCache))
+	s.mux.HandleFunc("POST /api/v1/refresh/{domain}", s.admin(s.refresh))
+	s.mux.HandleFunc("GET /api/v1/domains", s.listDomains)
+	s.mux.HandleFunc("GET /api/v1/domains/{name}", s.getDomain)
+	s.mux.HandleFunc("GET /api/v1/domains/{name}/history", s.getHistory)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// admin requires the token to call the given handler.
+func (s *Server) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			writeError(w, http.StatusForbidden, "admin API is disabled, set a token to enable it")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+type cacheEntry struct {
+	Domain      string    `json:"domain"`
+	Host        string    `json:"host,omitempty"`
+	Options     string    `json:"options,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	WhoisServer string    `json:"whois_server,omitempty"`
+	Expiry      time.Time `json:"expiry"`
+	Stored      time.Time `json:"stored"`
+	Age         float64   `json:"age_seconds"`
+	Expires     time.Time `json:"expires,omitzero"`
+}
+
+func (s *Server) listCache(w http.ResponseWriter, _ *http.Request) {
+	entries := []cacheEntry{}
+	for _, entry := range s.cache.Entries() {
+		entries = append(entries, cacheEntry{
+			Domain:      entry.Key.Domain,
+			Host:        entry.Key.Host,
+			Options:     entry.Key.Options,
+			Source:      entry.Result.Backend,
+			WhoisServer: entry.Result.WhoisServer,
+			Expiry:      entry.Result.Expiry,
+			Stored:      entry.Stored,
+			Age:         time.Since(entry.Stored).Seconds(),
+			Expires:     entry.Expires,
+		})
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) deleteCache(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	if s.cache.Invalidate(domain) == 0 {
+		writeError(w, http.StatusNotFound, "no cache entries for "+domain)
+		return
+	}
+	log.Info().Msgf("invalidated cache entries for %s", domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type refreshed struct {
+	Domain      string    `json:"domain"`
+	Source      string    `json:"source,omitempty"`
+	WhoisServer string    `json:"whois_server,omitempty"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+func (s *Server) refresh(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	host := r.URL.Query().Get("host")
+	s.cache.Invalidate(domain)
+
+	var result client.Result
+	var err error
+	if s.refresher != nil {
+		result, err = s.refresher.RefreshDomain(r.Context(), domain, host)
+	} else {
+		result, err = s.cache.Lookup(r.Context(), domain, host)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to refresh %s", domain)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	log.Info().Msgf("refreshed %s", domain)
+	writeJSON(w, http.StatusOK, refreshed{
+		Domain:      domain,
+		Source:      result.Backend,
+		WhoisServer: result.WhoisServer,
+		Expiry:      result.Expiry,
+	})
+}
+
+type domain struct {
+	Name        string    `json:"name"`
+	Host        string    `json:"host,omitempty"`
+	Expiry      time.Time `json:"expiry,omitzero"`
+	DaysLeft    *float64  `json:"days_left,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	WhoisServer string    `json:"whois_server,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	// WarningDays and CriticalDays are the thresholds of the domain.
+	WarningDays  int `json:"warning_days"`
+	CriticalDays int `json:"critical_days"`
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, _ *http.Request) {
+	domains := []domain{}
+	if s.refresher != nil {
+		entries := s.cache.Entries()
+		for _, status := range s.refresher.Statuses() {
+			domains = append(domains, newDomain(status, entries))
+		}
+	}
+	writeJSON(w, http.StatusOK, domains)
+}
+
+func (s *Server) getDomain(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if s.refresher == nil {
+		writeError(w, http.StatusNotFound, "unknown domain "+name)
+		return
+	}
+	status, ok := s.refresher.Status(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown domain "+name)
+		return
+	}
+	writeJSON(w, http.StatusOK, newDomain(status, s.cache.Entries()))
+}
+
+// newDomain returns the details of a domain from its status, using the
+// cache entries if it was not looked up successfully yet.
+func newDomain(status refresher.Status, entries []client.CacheEntry) domain {
+	result := status.Result
+	if status.LastSuccess.IsZero() {
+		for _, entry := range entries {
+			if entry.Key.Domain == status.Domain.Name && entry.Stored.After(status.LastSuccess) {
+				result = entry.Result
+				status.LastSuccess = entry.Stored
+			}
+		}
+	}
+
+	d := domain{
+		Name:        status.Domain.Name,
+		Host:        status.Domain.Host,
+		Expiry:      result.Expiry,
+		Source:      result.Backend,
+		WhoisServer: result.WhoisServer,
+		LastSuccess: status.LastSuccess,
+		LastAttempt: status.LastAttempt,
+	}
+	d.WarningDays, d.CriticalDays = status.Domain.Thresholds()
+	if !result.Expiry.IsZero() {
+		days := math.Floor(time.Until(result.Expiry).Hours() / 24)
+		d.DaysLeft = &days
+	}
+	if status.LastError != nil {
+		d.LastError = status.LastError.Error()
+	}
+	return d
+}
+
+type domainHistory struct {
+	Domain string `json:"domain"`
+	history.Record
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var record history.Record
+	ok := false
+	if s.history != nil {
+		record, ok = s.history.Record(name)
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no history for "+name)
+		return
+	}
+	writeJSON(w, http.StatusOK, domainHistory{Domain: name, Record: record})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
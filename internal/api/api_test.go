@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/history"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "s3cr3t"
+
+type fakeClient struct {
+	result *client.Result
+}
+
+func (f fakeClient) Lookup(_ context.Context, domain string, _ string) (client.Result, error) {
+	if domain == "fail.com" {
+		return client.Result{}, errors.New("fail")
+	}
+	return *f.result, nil
+}
+
+type fakeRefresher struct {
+	cache    *client.CachedClient
+	hosts    map[string]string
+	statuses []refresher.Status
+}
+
+func (f fakeRefresher) RefreshDomain(ctx context.Context, name, host string) (client.Result, error) {
+	f.hosts[name] = host
+	return f.cache.Lookup(ctx, name, host)
+}
+
+func (f fakeRefresher) Statuses() []refresher.Status {
+	return f.statuses
+}
+
+func (f fakeRefresher) Status(name string) (refresher.Status, bool) {
+	for _, status := range f.statuses {
+		if status.Domain.Name == name {
+			return status, true
+		}
+	}
+	return refresher.Status{}, false
+}
+
+func newCache(t *testing.T, result *client.Result) *client.CachedClient {
+	t.Helper()
+	return client.NewCachedClient(fakeClient{result: result}, cache.New(time.Hour, time.Hour), "policy=registry")
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, token string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestAuth(t *testing.T) {
+	cached := newCache(t, &client.Result{})
+	for _, tt := range []struct {
+		name   string
+		server *Server
+		token  string
+		status int
+	}{
+		{name: "disabled", server: New(cached), token: token, status: http.StatusForbidden},
+		{name: "missing", server: New(cached, WithToken(token)), status: http.StatusUnauthorized},
+		{name: "wrong", server: New(cached, WithToken(token)), token: "foo", status: http.StatusUnauthorized},
+		{name: "ok", server: New(cached, WithToken(token)), token: token, status: http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.server)
+			t.Cleanup(srv.Close)
+			resp := request(t, srv, http.MethodGet, "/api/v1/cache", tt.token)
+			require.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+
+	t.Run("read only", func(t *testing.T) {
+		srv := httptest.NewServer(New(cached, WithRefresher(fakeRefresher{})))
+		t.Cleanup(srv.Close)
+		resp := request(t, srv, http.MethodGet, "/api/v1/domains", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = request(t, srv, http.MethodDelete, "/api/v1/cache/foo.com", "")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestListCache(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	cached := newCache(t, &client.Result{Expiry: expiry, Backend: "rdap"})
+	_, err := cached.Lookup(context.Background(), "foo.com", "whois.foo")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(New(cached, WithToken(token)))
+	t.Cleanup(srv.Close)
+
+	resp := request(t, srv, http.MethodGet, "/api/v1/cache", token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var entries []cacheEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, "foo.com", entries[0].Domain)
+	require.Equal(t, "whois.foo", entries[0].Host)
+	require.Equal(t, "policy=registry", entries[0].Options)
+	require.Equal(t, "rdap", entries[0].Source)
+	require.Equal(t, expiry, entries[0].Expiry)
+	require.GreaterOrEqual(t, entries[0].Age, 0.0)
+	require.True(t, entries[0].Expires.After(entries[0].Stored))
+
+	resp = request(t, srv, http.MethodPost, "/api/v1/cache", token)
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestDeleteCache(t *testing.T) {
+	cached := newCache(t, &client.Result{Expiry: time.Now()})
+	_, err := cached.Lookup(context.Background(), "foo.com", "")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(New(cached, WithToken(token)))
+	t.Cleanup(srv.Close)
+
+	resp := request(t, srv, http.MethodDelete, "/api/v1/cache/foo.com", token)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Empty(t, cached.Entries())
+
+	resp = request(t, srv, http.MethodDelete, "/api/v1/cache/foo.com", token)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRefresh(t *testing.T) {
+	result := &client.Result{Expiry: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)}
+	cached := newCache(t, result)
+	_, err := cached.Lookup(context.Background(), "foo.com", "")
+	require.NoError(t, err)
+
+	// the domain was renewed
+	renewed := time.Date(2031, 1, 2, 0, 0, 0, 0, time.UTC)
+	result.Expiry = renewed
+
+	fake := fakeRefresher{cache: cached, hosts: map[string]string{}}
+	srv := httptest.NewServer(New(cached, WithToken(token), WithRefresher(fake)))
+	t.Cleanup(srv.Close)
+
+	resp := request(t, srv, http.MethodPost, "/api/v1/refresh/foo.com?host=whois.foo", token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got refreshed
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "foo.com", got.Domain)
+	require.Equal(t, renewed, got.Expiry)
+	require.Equal(t, map[string]string{"foo.com": "whois.foo"}, fake.hosts)
+
+	entries := cached.Entries()
+	require.Len(t, entries, 1)
+	require.Equal(t, renewed, entries[0].Result.Expiry)
+
+	resp = request(t, srv, http.MethodPost, "/api/v1/refresh/fail.com", token)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestDomains(t *testing.T) {
+	expiry := time.Now().Add(10*24*time.Hour + time.Hour)
+	attempt := time.Now().Add(-time.Minute)
+	cached := newCache(t, &client.Result{Expiry: expiry, Backend: "whois"})
+	_, err := cached.Lookup(context.Background(), "cached.com", "")
+	require.NoError(t, err)
+
+	fake := fakeRefresher{statuses: []refresher.Status{
+		{
+			Domain:      safeconfig.Domain{Name: "foo.com", Host: "whois.foo", WarningDays: 60},
+			Result:      client.Result{Expiry: expiry, Backend: "rdap", WhoisServer: "whois.foo"},
+			LastSuccess: attempt.Add(-time.Hour),
+			LastAttempt: attempt,
+			LastError:   errors.New("timeout"),
+		},
+		{Domain: safeconfig.Domain{Name: "cached.com"}},
+		{Domain: safeconfig.Domain{Name: "new.com"}},
+	}}
+	srv := httptest.NewServer(New(cached, WithRefresher(fake)))
+	t.Cleanup(srv.Close)
+
+	resp := request(t, srv, http.MethodGet, "/api/v1/domains", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var domains []domain
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&domains))
+	require.Len(t, domains, 3)
+
+	require.Equal(t, "foo.com", domains[0].Name)
+	require.Equal(t, "whois.foo", domains[0].Host)
+	require.Equal(t, "rdap", domains[0].Source)
+	require.Equal(t, "whois.foo", domains[0].WhoisServer)
+	require.Equal(t, 10.0, *domains[0].DaysLeft)
+	require.Equal(t, "timeout", domains[0].LastError)
+	require.Equal(t, 60, domains[0].WarningDays)
+	require.Equal(t, safeconfig.DefaultCriticalDays, domains[0].CriticalDays)
+	require.WithinDuration(t, attempt, domains[0].LastAttempt, time.Millisecond)
+
+	require.Equal(t, "cached.com", domains[1].Name)
+	require.Equal(t, "whois", domains[1].Source)
+	require.NotNil(t, domains[1].DaysLeft)
+	require.False(t, domains[1].LastSuccess.IsZero())
+
+	require.Equal(t, "new.com", domains[2].Name)
+	require.Nil(t, domains[2].DaysLeft)
+
+	resp = request(t, srv, http.MethodGet, "/api/v1/domains/foo.com", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got domain
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "foo.com", got.Name)
+
+	resp = request(t, srv, http.MethodGet, "/api/v1/domains/unknown.com", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHistory(t *testing.T) {
+	hist, err := history.New("")
+	require.NoError(t, err)
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	hist.Observe("foo.com", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), seen)
+	hist.Observe("foo.com", time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC), seen.Add(time.Hour))
+
+	srv := httptest.NewServer(New(newCache(t, nil), WithHistory(hist)))
+	t.Cleanup(srv.Close)
+
+	resp := request(t, srv, http.MethodGet, "/api/v1/domains/foo.com/history", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var got struct {
+		Domain       string                `json:"domain"`
+		Observations []history.Observation `json:"observations"`
+		Renewals     []history.Renewal     `json:"renewals"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "foo.com", got.Domain)
+	require.Len(t, got.Observations, 2)
+	require.Equal(t, []history.Renewal{{
+		At:   seen.Add(time.Hour),
+		From: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+		Days: 365,
+	}}, got.Renewals)
+
+	resp = request(t, srv, http.MethodGet, "/api/v1/domains/bar.com/history", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/rs/zerolog/log"
+)
+
+// nolint: gochecknoglobals
+var (
+	//go:embed status.html
+	statusHTML     string
+	statusTemplate = template.Must(template.New("status").Parse(statusHTML))
+)
+
+// Statuser returns the status of the domains.
+type Statuser interface {
+	Statuses() []refresher.Status
+}
+
+type statusPage struct {
+	cache     *client.CachedClient
+	refresher Statuser
+	prefix    string
+}
+
+// NewStatusPage returns the status page handler, listing the configured
+// domains and the ones probed since startup. The prefix is prepended to
+// all the links.
+func NewStatusPage(cache *client.CachedClient, refresher Statuser, prefix string) http.Handler {
+	return statusPage{
+		cache:     cache,
+		refresher: refresher,
+		prefix:    prefix,
+	}
+}
+
+type statusRow struct {
+	domain
+	Class string
+}
+
+type statusData struct {
+	Prefix   string
+	Sort     string
+	Warning  int
+	Critical int
+	Rows     []statusRow
+}
+
+func (p statusPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := statusData{
+		Prefix:   p.prefix,
+		Sort:     r.URL.Query().Get("sort"),
+		Warning:  safeconfig.DefaultWarningDays,
+		Critical: safeconfig.DefaultCriticalDays,
+	}
+	for _, d := range p.domains() {
+		data.Rows = append(data.Rows, statusRow{domain: d, Class: class(d)})
+	}
+	sortRows(data.Rows, data.Sort)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, data); err != nil {
+		log.Error().Err(err).Msg("failed to render status page")
+	}
+}
+
+// domains returns the configured domains followed by the ones only found
+// in the cache, e.g. probed through /probe.
+func (p statusPage) domains() []domain {
+	entries := p.cache.Entries()
+	var domains []domain
+	seen := map[string]bool{}
+	if p.refresher != nil {
+		for _, status := range p.refresher.Statuses() {
+			seen[status.Domain.Name] = true
+			domains = append(domains, newDomain(status, entries))
+		}
+	}
+	for _, entry := range entries {
+		if seen[entry.Key.Domain] {
+			continue
+		}
+		seen[entry.Key.Domain] = true
+		status := refresher.Status{Domain: safeconfig.Domain{Name: entry.Key.Domain, Host: entry.Key.Host}}
+		domains = append(domains, newDomain(status, entries))
+	}
+	return domains
+}
+
+func class(d domain) string {
+	switch {
+	case d.DaysLeft == nil:
+		return "unknown"
+	case *d.DaysLeft <= float64(d.CriticalDays):
+		return "critical"
+	case *d.DaysLeft <= float64(d.WarningDays):
+		return "warning"
+	default:
+		return "ok"
+	}
+}
+
+// sortRows sorts by name, or by days to expiry, unknown ones last.
+func sortRows(rows []statusRow, by string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if by == "name" {
+			return a.Name < b.Name
+		}
+		switch {
+		case a.DaysLeft == nil:
+			return false
+		case b.DaysLeft == nil:
+			return true
+		default:
+			return *a.DaysLeft < *b.DaysLeft
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusPage(t *testing.T) {
+	cached := newCache(t, &client.Result{Expiry: time.Now().Add(100*24*time.Hour + time.Hour), Backend: "rdap"})
+	_, err := cached.Lookup(context.Background(), "probed.com", "")
+	require.NoError(t, err)
+
+	fake := fakeRefresher{statuses: []refresher.Status{
+		{
+			Domain:      safeconfig.Domain{Name: "later.com"},
+			Result:      client.Result{Expiry: time.Now().Add(20*24*time.Hour + time.Hour), Backend: "whois", WhoisServer: "whois.later"},
+			LastSuccess: time.Now(),
+		},
+		{
+			Domain:      safeconfig.Domain{Name: "soon.com"},
+			Result:      client.Result{Expiry: time.Now().Add(3*24*time.Hour + time.Hour), Backend: "rdap"},
+			LastSuccess: time.Now(),
+		},
+		{
+			Domain:      safeconfig.Domain{Name: "policy.com", WarningDays: 120, CriticalDays: 60},
+			Result:      client.Result{Expiry: time.Now().Add(90*24*time.Hour + time.Hour), Backend: "rdap"},
+			LastSuccess: time.Now(),
+		},
+		{
+			Domain:      safeconfig.Domain{Name: "broken.com", Host: "whois.broken"},
+			LastAttempt: time.Now(),
+			LastError:   &client.MultiError{client.ErrTimeout},
+		},
+	}}
+
+	srv := httptest.NewServer(NewStatusPage(cached, fake, "/exporters/domains"))
+	t.Cleanup(srv.Close)
+
+	body := get(t, srv.URL+"/")
+	require.Contains(t, body, `href="/exporters/domains/metrics"`)
+	require.Contains(t, body, `action="/exporters/domains/probe"`)
+	require.Contains(t, body, `<td class="days">3</td>`)
+	require.Contains(t, body, `<tr class="critical">`)
+	require.Contains(t, body, `<tr class="warning">`)
+	require.Contains(t, body, `<tr class="ok">`)
+	require.Contains(t, body, `<tr class="unknown">`)
+	require.Contains(t, body, "whois.later")
+	require.Contains(t, body, `<td class="error">timeout</td>`)
+	require.Contains(t, body, `href="/exporters/domains/probe?target=broken.com&host=whois.broken"`)
+	requireOrder(t, body, "soon.com", "later.com", "policy.com", "probed.com", "broken.com")
+	require.Regexp(t, `<tr class="warning">\s*<td><a [^>]*>policy.com`, body)
+
+	body = get(t, srv.URL+"/?sort=name")
+	requireOrder(t, body, "broken.com", "later.com", "policy.com", "probed.com", "soon.com")
+
+	resp, err := http.Get(srv.URL + "/foo")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestStatusPageEmpty(t *testing.T) {
+	srv := httptest.NewServer(NewStatusPage(newCache(t, &client.Result{}), nil, ""))
+	t.Cleanup(srv.Close)
+	body := get(t, srv.URL+"/")
+	require.Contains(t, body, `href="/metrics"`)
+	require.Contains(t, body, "No domains yet")
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func requireOrder(t *testing.T, body string, names ...string) {
+	t.Helper()
+	last := -1
+	for _, name := range names {
+		idx := strings.Index(body, ">"+name+"<")
+		require.Greater(t, idx, last, "%s out of order", name)
+		last = idx
+	}
+}
@@ -2,39 +2,206 @@ package client
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 )
 
-type cachedClient struct {
-	client Client
-	cache  *cache.Cache
+// CachedClient is a client that caches the successful results of another
+// client. It is also a prometheus.Collector exposing the cache metrics.
+type CachedClient struct {
+	client   Client
+	cache    *cache.Cache
+	options  string
+	inflight *inflight
+
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions prometheus.Counter
+	entries   *prometheus.Desc
+	entryAge  *prometheus.Desc
+}
+
+// CacheKey identifies a cached result.
+type CacheKey struct {
+	Domain string
+	Host   string
+	// Options describes the options of the backends, so clients configured
+	// differently don't share results.
+	Options string
+}
+
+// String returns the key as stored in the cache.
+func (k CacheKey) String() string {
+	return strings.Join([]string{k.Domain, k.Host, k.Options}, "|")
+}
+
+// CacheEntry is a cached result.
+type CacheEntry struct {
+	Key     CacheKey
+	Result  Result
+	Stored  time.Time
+	Expires time.Time
 }
 
-// NewCachedClient returns a new cached client.
-func NewCachedClient(client Client, cache *cache.Cache) Client {
-	return cachedClient{
-		client: client,
-		cache:  cache,
+// NewCachedClient returns a new cached client. The options describe the
+// configuration of the wrapped client and are part of the cache keys.
+func NewCachedClient(client Client, cache *cache.Cache, options string) *CachedClient {
+	c := &CachedClient{
+		client:   client,
+		cache:    cache,
+		options:  options,
+		inflight: newInflight(),
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "domain_cache_hits_total",
+			Help: "total of lookups served from the cache",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "domain_cache_misses_total",
+			Help: "total of lookups not found in the cache",
+		}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "domain_cache_evictions_total",
+			Help: "total of entries removed from the cache",
+		}),
+		entries: prometheus.NewDesc(
+			"domain_cache_entries",
+			"number of entries in the cache",
+			nil,
+			nil,
+		),
+		entryAge: prometheus.NewDesc(
+			"domain_cache_entry_age_seconds",
+			"how long ago the oldest cached result of each domain was stored",
+			[]string{"domain"},
+			nil,
+		),
 	}
+	cache.OnEvicted(func(string, any) {
+		c.evictions.Inc()
+	})
+	return c
 }
 
-func (c cachedClient) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
-	cached, found := c.cache.Get(domain)
+// WithClient returns a cached client for another client, sharing the cache,
+// the metrics and the in-flight lookups. The options tell their results
+// apart.
+func (c *CachedClient) WithClient(client Client, options string) *CachedClient {
+	clone := *c
+	clone.client = client
+	clone.options = options
+	return &clone
+}
+
+func (c *CachedClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	key := CacheKey{Domain: domain, Host: host, Options: c.options}
+	cached, found := c.cache.Get(key.String())
 	if found {
 		log.Debug().Msgf("using result from cache for %s", domain)
-		return cached.(time.Time), nil
+		c.hits.Inc()
+		return cached.(CacheEntry).Result, nil
+	}
+	var hit bool
+	live, err, shared := c.inflight.do(ctx, key.String(), func() (result Result, err error) {
+		result, hit, err = c.live(ctx, key)
+		return result, err
+	})
+	if shared {
+		log.Debug().Msgf("shared in-flight lookup of %s", domain)
+	}
+	if hit {
+		c.hits.Inc()
+	} else {
+		c.misses.Inc()
+	}
+	return live, err
+}
+
+// live looks the key up with the client, caching the result. The cache is
+// checked again first, as a lookup of the key may have finished since the
+// caller missed it, in which case it reports a hit.
+func (c *CachedClient) live(ctx context.Context, key CacheKey) (Result, bool, error) {
+	if cached, found := c.cache.Get(key.String()); found {
+		log.Debug().Msgf("using result from cache for %s", key.Domain)
+		return cached.(CacheEntry).Result, true, nil
 	}
-	log.Debug().Msgf("getting live result for %s", domain)
-	live, err := c.client.ExpireTime(ctx, domain, host)
+	log.Debug().Msgf("getting live result for %s", key.Domain)
+	live, err := c.client.Lookup(ctx, key.Domain, key.Host)
 	if err == nil {
-		log.Debug().Msgf("caching result for %s", domain)
-		c.cache.Set(domain, live, cache.DefaultExpiration)
-		return live, nil
+		log.Debug().Msgf("caching result for %s", key.Domain)
+		c.cache.Set(key.String(), CacheEntry{Key: key, Result: live, Stored: time.Now()}, cache.DefaultExpiration)
+		return live, false, nil
 	}
+	log.Debug().Err(err).Msgf("not caching %s because it errored", key.Domain)
+	return live, false, err
+}
 
-	log.Debug().Err(err).Msgf("not caching %s because it errored", domain)
-	return live, err
+// Describe all metrics
+func (c *CachedClient) Describe(ch chan<- *prometheus.Desc) {
+	c.hits.Describe(ch)
+	c.misses.Describe(ch)
+	c.evictions.Describe(ch)
+	ch <- c.entries
+	ch <- c.entryAge
+}
+
+// Collect all metrics
+func (c *CachedClient) Collect(ch chan<- prometheus.Metric) {
+	c.hits.Collect(ch)
+	c.misses.Collect(ch)
+	c.evictions.Collect(ch)
+
+	entries := c.Entries()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(len(entries)))
+	oldest := map[string]time.Time{}
+	for _, entry := range entries {
+		if stored, ok := oldest[entry.Key.Domain]; !ok || entry.Stored.Before(stored) {
+			oldest[entry.Key.Domain] = entry.Stored
+		}
+	}
+	for domain, stored := range oldest {
+		ch <- prometheus.MustNewConstMetric(
+			c.entryAge,
+			prometheus.GaugeValue,
+			time.Since(stored).Seconds(),
+			domain,
+		)
+	}
+}
+
+// Entries returns the entries in the cache, sorted by key.
+func (c *CachedClient) Entries() []CacheEntry {
+	var entries []CacheEntry
+	for _, item := range c.cache.Items() {
+		entry, ok := item.Object.(CacheEntry)
+		if !ok {
+			continue
+		}
+		if item.Expiration > 0 {
+			entry.Expires = time.Unix(0, item.Expiration)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key.String() < entries[j].Key.String()
+	})
+	return entries
+}
+
+// Invalidate removes all the cached results of the given domain, returning
+// how many were removed.
+func (c *CachedClient) Invalidate(domain string) int {
+	var removed int
+	for _, entry := range c.Entries() {
+		if entry.Key.Domain != domain {
+			continue
+		}
+		c.cache.Delete(entry.Key.String())
+		removed++
+	}
+	return removed
 }
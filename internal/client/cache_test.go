@@ -3,10 +3,14 @@ package client
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,14 +18,14 @@ type testClient struct {
 	result *time.Time
 }
 
-func (f testClient) ExpireTime(_ context.Context, _ string, _ string) (time.Time, error) {
-	return *f.result, nil
+func (f testClient) Lookup(_ context.Context, _ string, _ string) (Result, error) {
+	return Result{Expiry: *f.result}, nil
 }
 
 type errTestClient struct{}
 
-func (f errTestClient) ExpireTime(_ context.Context, _ string, _ string) (time.Time, error) {
-	return time.Now(), fmt.Errorf("failed to get domain info blah")
+func (f errTestClient) Lookup(_ context.Context, _ string, _ string) (Result, error) {
+	return Result{}, fmt.Errorf("failed to get domain info blah")
 }
 
 func TestCachedClient(t *testing.T) {
@@ -31,13 +35,13 @@ func TestCachedClient(t *testing.T) {
 	domain := "foo.bar"
 	host := ""
 
-	cli := NewCachedClient(testClient{result: &expected}, cache)
+	cli := NewCachedClient(testClient{result: &expected}, cache, "")
 
 	// test getting from out fake client
 	t.Run("get fresh", func(t *testing.T) {
-		res, err := cli.ExpireTime(ctx, domain, host)
+		res, err := cli.Lookup(ctx, domain, host)
 		require.NoError(t, err)
-		require.Equal(t, expected, res)
+		require.Equal(t, expected, res.Expiry)
 	})
 
 	// here we change the inner fake client result, but the result
@@ -45,32 +49,222 @@ func TestCachedClient(t *testing.T) {
 	t.Run("get from cache", func(t *testing.T) {
 		oldExpected := expected
 		expected = time.Now()
-		res, err := cli.ExpireTime(ctx, domain, host)
+		res, err := cli.Lookup(ctx, domain, host)
 		require.NoError(t, err)
-		require.Equal(t, oldExpected, res)
+		require.Equal(t, oldExpected, res.Expiry)
 	})
 
 	// here we flush the cache and verify that the result is the one
 	// from the fake client
 	t.Run("flush cache", func(t *testing.T) {
 		cache.Flush()
-		res, err := cli.ExpireTime(ctx, domain, host)
+		res, err := cli.Lookup(ctx, domain, host)
 		require.NoError(t, err)
-		require.Equal(t, expected, res)
+		require.Equal(t, expected, res.Expiry)
 	})
 
 	t.Run("do not cache errors", func(t *testing.T) {
 		cache.Flush()
 
-		cli := NewCachedClient(errTestClient{}, cache)
-		_, err := cli.ExpireTime(ctx, domain, host)
+		cli := NewCachedClient(errTestClient{}, cache, "")
+		_, err := cli.Lookup(ctx, domain, host)
 		require.Error(t, err)
 
-		_, err = cli.ExpireTime(ctx, domain, host)
+		_, err = cli.Lookup(ctx, domain, host)
 		require.Error(t, err)
 
-		cached, got := cache.Get(domain)
-		require.Nil(t, cached)
-		require.False(t, got)
+		require.Zero(t, cache.ItemCount())
 	})
+
+	t.Run("key by host and options", func(t *testing.T) {
+		cache.Flush()
+		first := expected
+		_, err := cli.Lookup(ctx, domain, "whois.a")
+		require.NoError(t, err)
+
+		expected = time.Now().Add(time.Hour)
+		res, err := cli.Lookup(ctx, domain, "whois.b")
+		require.NoError(t, err)
+		require.Equal(t, expected, res.Expiry)
+
+		other := NewCachedClient(testClient{result: &expected}, cache, "policy=registrar")
+		_, err = other.Lookup(ctx, domain, "whois.a")
+		require.NoError(t, err)
+
+		entries := cli.Entries()
+		require.Len(t, entries, 3)
+		require.Equal(t, CacheKey{Domain: domain, Host: "whois.a"}, entries[0].Key)
+		require.Equal(t, first, entries[0].Result.Expiry)
+		require.Equal(t, CacheKey{Domain: domain, Host: "whois.a", Options: "policy=registrar"}, entries[1].Key)
+		require.Equal(t, CacheKey{Domain: domain, Host: "whois.b"}, entries[2].Key)
+		require.False(t, entries[2].Stored.IsZero())
+		require.True(t, entries[2].Expires.After(entries[2].Stored))
+
+		require.Equal(t, 3, cli.Invalidate(domain))
+		require.Equal(t, 0, cli.Invalidate(domain))
+		require.Empty(t, cli.Entries())
+	})
+}
+
+func TestCacheMetrics(t *testing.T) {
+	ctx := context.Background()
+	cache := cache.New(1*time.Minute, 1*time.Minute)
+	expected := time.Now()
+	cli := NewCachedClient(testClient{result: &expected}, cache, "")
+
+	for _, domain := range []string{"foo.bar", "foo.bar", "bar.foo", "foo.bar"} {
+		_, err := cli.Lookup(ctx, domain, "")
+		require.NoError(t, err)
+	}
+	cache.Delete(CacheKey{Domain: "bar.foo"}.String())
+
+	require.NoError(t, testutil.CollectAndCompare(cli, strings.NewReader(`
+# HELP domain_cache_entries number of entries in the cache
+# TYPE domain_cache_entries gauge
+domain_cache_entries 1
+# HELP domain_cache_evictions_total total of entries removed from the cache
+# TYPE domain_cache_evictions_total counter
+domain_cache_evictions_total 1
+# HELP domain_cache_hits_total total of lookups served from the cache
+# TYPE domain_cache_hits_total counter
+domain_cache_hits_total 2
+# HELP domain_cache_misses_total total of lookups not found in the cache
+# TYPE domain_cache_misses_total counter
+domain_cache_misses_total 2
+`), "domain_cache_entries", "domain_cache_evictions_total", "domain_cache_hits_total", "domain_cache_misses_total"))
+	require.Equal(t, 1, testutil.CollectAndCount(cli, "domain_cache_entry_age_seconds"))
+
+	other := time.Now().Add(time.Hour)
+	module := cli.WithClient(testClient{result: &other}, "protocols=whois")
+	res, err := module.Lookup(ctx, "foo.bar", "")
+	require.NoError(t, err)
+	require.Equal(t, other, res.Expiry)
+	require.Len(t, cli.Entries(), 2)
+	require.Equal(t, float64(3), testutil.ToFloat64(cli.misses))
+}
+
+// countingClient blocks until released, counting its lookups.
+type countingClient struct {
+	calls   *atomic.Int32
+	release chan struct{}
+}
+
+func (c countingClient) Lookup(_ context.Context, _ string, _ string) (Result, error) {
+	c.calls.Add(1)
+	<-c.release
+	return Result{Expiry: time.Unix(1893456000, 0)}, nil
+}
+
+func TestCachedClientCoalesces(t *testing.T) {
+	const callers = 10
+	ctx := context.Background()
+	backend := countingClient{calls: &atomic.Int32{}, release: make(chan struct{})}
+	cli := NewCachedClient(backend, cache.New(time.Minute, time.Minute), "")
+
+	var wg sync.WaitGroup
+	results := make(chan Result, callers)
+	for range callers {
+		wg.Go(func() {
+			res, err := cli.Lookup(ctx, "foo.bar", "")
+			require.NoError(t, err)
+			results <- res
+		})
+	}
+	require.Eventually(t, func() bool {
+		return waiters(cli, "foo.bar||") == callers-1
+	}, time.Second, time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+	close(results)
+
+	require.Equal(t, int32(1), backend.calls.Load())
+	require.Equal(t, float64(callers), testutil.ToFloat64(cli.misses))
+	for res := range results {
+		require.Equal(t, time.Unix(1893456000, 0), res.Expiry)
+	}
+
+	t.Run("different keys are not shared", func(t *testing.T) {
+		_, err := cli.Lookup(ctx, "foo.bar", "whois.foo")
+		require.NoError(t, err)
+		require.Equal(t, int32(2), backend.calls.Load())
+	})
+
+	t.Run("waiters give up with their context", func(t *testing.T) {
+		backend := countingClient{calls: &atomic.Int32{}, release: make(chan struct{})}
+		cli := NewCachedClient(backend, cache.New(time.Minute, time.Minute), "")
+		go func() { _, _ = cli.Lookup(ctx, "foo.bar", "") }()
+		require.Eventually(t, func() bool { return backend.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		waiter, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := cli.Lookup(waiter, "foo.bar", "")
+		require.ErrorIs(t, err, ErrTimeout)
+		require.ErrorIs(t, err, context.Canceled)
+		close(backend.release)
+	})
+
+	t.Run("finished lookups are not repeated", func(t *testing.T) {
+		backend := countingClient{calls: &atomic.Int32{}, release: make(chan struct{})}
+		close(backend.release)
+		cli := NewCachedClient(backend, cache.New(time.Minute, time.Minute), "")
+		_, err := cli.Lookup(ctx, "foo.bar", "")
+		require.NoError(t, err)
+
+		// a caller that missed the cache right before the lookup was stored.
+		res, hit, err := cli.live(ctx, CacheKey{Domain: "foo.bar"})
+		require.NoError(t, err)
+		require.True(t, hit)
+		require.Equal(t, time.Unix(1893456000, 0), res.Expiry)
+		require.Equal(t, int32(1), backend.calls.Load())
+	})
+}
+
+// waiters returns how many callers are waiting for the in-flight lookup of
+// the given key.
+func waiters(cli *CachedClient, key string) int {
+	cli.inflight.mutex.Lock()
+	defer cli.inflight.mutex.Unlock()
+	if c, ok := cli.inflight.calls[key]; ok {
+		return c.waiters
+	}
+	return 0
+}
+
+// slowClient takes the given time to answer, unless its context is done
+// first.
+type slowClient struct {
+	calls *atomic.Int32
+	delay time.Duration
+}
+
+func (c slowClient) Lookup(ctx context.Context, _ string, _ string) (Result, error) {
+	c.calls.Add(1)
+	select {
+	case <-time.After(c.delay):
+		return Result{Expiry: time.Unix(1893456000, 0)}, nil
+	case <-ctx.Done():
+		return Result{}, fmt.Errorf("failed to fetch: %w", ctx.Err())
+	}
+}
+
+func TestCachedClientCanceledLeader(t *testing.T) {
+	backend := slowClient{calls: &atomic.Int32{}, delay: 100 * time.Millisecond}
+	cli := NewCachedClient(backend, cache.New(time.Minute, time.Minute), "")
+
+	leader, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		_, err := cli.Lookup(leader, "foo.bar", "")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	require.Eventually(t, func() bool { return backend.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// the follower has no deadline, so it must not fail with the one of the
+	// leader.
+	res, err := cli.Lookup(context.Background(), "foo.bar", "")
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1893456000, 0), res.Expiry)
+	require.Equal(t, int32(2), backend.calls.Load())
+	wg.Wait()
 }
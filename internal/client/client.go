@@ -7,5 +7,25 @@ import (
 
 // Client is a DNS client impl.
 type Client interface {
-	ExpireTime(ctx context.Context, domain string, host string) (time.Time, error)
+	Lookup(ctx context.Context, domain string, host string) (Result, error)
+}
+
+// Result is the outcome of a successful lookup.
+type Result struct {
+	// Expiry is when the domain expires, in UTC.
+	Expiry time.Time
+	// Confidence is how sure the parser is about Expiry, 1 meaning it
+	// matched a known date layout.
+	Confidence float64
+	// RegistryExpiry is the expiry date reported by the registry, if any.
+	RegistryExpiry time.Time
+	// RegistrarExpiry is the expiry date reported by the registrar, if any.
+	RegistrarExpiry time.Time
+	// WhoisServer is the whois server whose answer was used, if any.
+	WhoisServer string
+	// Backend is the backend which returned the result, e.g. rdap.
+	Backend string
+	// Disagreement is whether the clients of a consensus client returned
+	// different expiry dates.
+	Disagreement bool
 }
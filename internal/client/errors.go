@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// Typed errors returned by the clients, so failures can be told apart.
+var (
+	// ErrDomainNotFound is returned when the registry has no record of the
+	// domain, e.g. it was never registered or it was deleted.
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrTimeout is returned when the lookup did not finish in time.
+	ErrTimeout = errors.New("timeout")
+	// ErrNetwork is returned when the backend could not be reached.
+	ErrNetwork = errors.New("network error")
+	// ErrRateLimited is returned when the backend refused to answer due to
+	// rate limiting.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrNoExpiry is returned when the response has no expiry date.
+	ErrNoExpiry = errors.New("no expiry date found")
+	// ErrBadDate is returned when the expiry date could not be parsed.
+	ErrBadDate = errors.New("invalid expiry date")
+)
+
+// nolint: gochecknoglobals
+var (
+	rateLimitRE = regexp.MustCompile(`(?i)too many (requests|queries)|rate limit|limit exceeded|\b429\b`)
+	// timeoutRE and networkRE match errors whose cause was lost along the
+	// way, e.g. formatted with %v by a library.
+	timeoutRE = regexp.MustCompile(`(?i)i/o timeout|deadline exceeded|context canceled`)
+	networkRE = regexp.MustCompile(`(?i)dial tcp|no such host|connection (refused|reset)|network is unreachable`)
+)
+
+// Reason returns a short description of the error, suitable as a metric
+// label value.
+func Reason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrDomainNotFound):
+		return "not_found"
+	case errors.Is(err, ErrNoExpiry):
+		return "parse_no_key"
+	case errors.Is(err, ErrBadDate):
+		return "parse_bad_date"
+	case errors.Is(err, ErrNetwork):
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// IsNotFound returns whether the error means the domain is not registered.
+// The errors of a multi client only do if all of them agree, so a backend
+// that failed for other reasons can't make a domain look unregistered.
+func IsNotFound(err error) bool {
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		return errors.Is(err, ErrDomainNotFound)
+	}
+	for _, err := range errs {
+		if !IsNotFound(err) {
+			return false
+		}
+	}
+	return len(errs) != 0
+}
+
+// Classify wraps low level errors, such as the ones from the network, into
+// one of the typed errors. Errors that are already typed, or that can't be
+// classified, are returned as is.
+func Classify(err error) error {
+	if err == nil || Reason(err) != "unknown" {
+		return err
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled),
+		errors.As(err, &netErr) && netErr.Timeout(),
+		timeoutRE.MatchString(err.Error()):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case rateLimitRE.MatchString(err.Error()):
+		return fmt.Errorf("%w: %w", ErrRateLimited, err)
+	case errors.As(err, &netErr),
+		networkRE.MatchString(err.Error()):
+		return fmt.Errorf("%w: %w", ErrNetwork, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		err    error
+		reason string
+	}{
+		{name: "nil", err: nil, reason: ""},
+		{name: "deadline", err: fmt.Errorf("failed: %w", context.DeadlineExceeded), reason: "timeout"},
+		{name: "canceled", err: context.Canceled, reason: "timeout"},
+		{name: "net timeout", err: &net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}, reason: "timeout"},
+		{name: "dns", err: &net.DNSError{Err: "no such host", Name: "whois.foo", IsNotFound: true}, reason: "network"},
+		{name: "dial", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, reason: "network"},
+		{name: "lost timeout", err: errors.New("failed to fetch whois request: read tcp 10.0.0.1:1234->1.2.3.4:43: i/o timeout"), reason: "timeout"},
+		{name: "lost cancel", err: errors.New("failed to fetch whois request: context canceled"), reason: "timeout"},
+		{name: "lost dns", err: errors.New("failed to fetch whois request: dial tcp: lookup whois.nic.com: no such host"), reason: "network"},
+		{name: "rate limit", err: errors.New("server said: Too many requests"), reason: "rate_limited"},
+		{name: "http 429", err: errors.New("RDAP server returned 429"), reason: "rate_limited"},
+		{name: "not found", err: fmt.Errorf("%w: No match", ErrDomainNotFound), reason: "not_found"},
+		{name: "no expiry", err: fmt.Errorf("%w: foo", ErrNoExpiry), reason: "parse_no_key"},
+		{name: "bad date", err: fmt.Errorf("%w: foo", ErrBadDate), reason: "parse_bad_date"},
+		{name: "unknown", err: errors.New("foo"), reason: "unknown"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Classify(tt.err)
+			require.Equal(t, tt.reason, Reason(err))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	notFound := fmt.Errorf("%w: No match", ErrDomainNotFound)
+	require.False(t, IsNotFound(nil))
+	require.False(t, IsNotFound(ErrTimeout))
+	require.True(t, IsNotFound(notFound))
+	require.True(t, IsNotFound(MultiError{notFound, notFound}))
+	require.True(t, IsNotFound(fmt.Errorf("failed: %w", MultiError{notFound})))
+	require.False(t, IsNotFound(MultiError{notFound, ErrTimeout}))
+	require.False(t, IsNotFound(MultiError{}))
+}
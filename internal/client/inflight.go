@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// inflight coalesces concurrent lookups with the same key, so they share a
+// single backend lookup.
+type inflight struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done   chan struct{}
+	result Result
+	err    error
+	// canceled is whether the call failed because the context of its
+	// caller was done, so its outcome tells nothing about the domain.
+	canceled bool
+	// waiters is how many other callers joined the call.
+	waiters int
+}
+
+func newInflight() *inflight {
+	return &inflight{calls: map[string]*call{}}
+}
+
+// do calls fn, unless a call with the same key is already in flight, in
+// which case it waits for its outcome, or for ctx to be done. It reports
+// whether the outcome was shared.
+//
+// fn must use ctx, the context of the caller. If the call fails because
+// that context is done, e.g. a scrape timed out, callers that are still
+// waiting try again instead of failing with it.
+func (g *inflight) do(ctx context.Context, key string, fn func() (Result, error)) (Result, error, bool) {
+	for {
+		g.mutex.Lock()
+		c, ok := g.calls[key]
+		if !ok {
+			c = &call{done: make(chan struct{})}
+			g.calls[key] = c
+			g.mutex.Unlock()
+			result, err := g.call(ctx, key, c, fn)
+			return result, err, false
+		}
+		c.waiters++
+		g.mutex.Unlock()
+
+		select {
+		case <-c.done:
+			if c.canceled && ctx.Err() == nil {
+				continue
+			}
+			return c.result, c.err, true
+		case <-ctx.Done():
+			return Result{}, Classify(fmt.Errorf("failed to wait for in-flight lookup: %w", ctx.Err())), true
+		}
+	}
+}
+
+// call runs fn as the given call, sharing its outcome once done.
+func (g *inflight) call(ctx context.Context, key string, c *call, fn func() (Result, error)) (Result, error) {
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+		close(c.done)
+	}()
+	c.result, c.err = fn()
+	c.canceled = c.err != nil && ctx.Err() != nil
+	return c.result, c.err
+}
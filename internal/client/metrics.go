@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Metrics holds the metrics of the backends, such as rdap and whois.
+type Metrics struct {
+	errors   *prometheus.CounterVec
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics returns the backend metrics, which must be registered.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "domain_backend_errors_total",
+				Help: "total of errors returned by each backend, by reason",
+			},
+			[]string{"backend", "reason"},
+		),
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "domain_backend_requests_total",
+				Help: "total of requests to each backend by TLD, result being success or the error reason",
+			},
+			[]string{"backend", "tld", "result"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "domain_backend_request_duration_seconds",
+				Help:    "how long the requests to each backend took, by TLD",
+				Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"backend", "tld"},
+		),
+	}
+}
+
+// Describe all metrics
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.errors.Describe(ch)
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect all metrics
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.errors.Collect(ch)
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// Instrument returns a client that records the requests, durations and
+// errors of the given one under the given backend name.
+func (m *Metrics) Instrument(backend string, client Client) Client {
+	return instrumentedClient{
+		client:  client,
+		backend: backend,
+		metrics: m,
+	}
+}
+
+type instrumentedClient struct {
+	client  Client
+	backend string
+	metrics *Metrics
+}
+
+func (c instrumentedClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	tld := tld(domain)
+	start := time.Now()
+	result, err := c.client.Lookup(ctx, domain, host)
+	if err != nil && errors.Is(context.Cause(ctx), errRaceLost) {
+		return result, err
+	}
+	c.metrics.duration.WithLabelValues(c.backend, tld).Observe(time.Since(start).Seconds())
+	if err != nil {
+		err = Classify(err)
+		TraceFrom(ctx).Add(c.backend, "error", err.Error())
+		c.metrics.errors.WithLabelValues(c.backend, Reason(err)).Inc()
+		c.metrics.requests.WithLabelValues(c.backend, tld, Reason(err)).Inc()
+		return result, err
+	}
+	c.metrics.requests.WithLabelValues(c.backend, tld, "success").Inc()
+	result.Backend = c.backend
+	return result, nil
+}
+
+// tld returns the public suffix of the domain, e.g. "co.uk".
+func tld(domain string) string {
+	suffix, _ := publicsuffix.PublicSuffix(strings.TrimSuffix(strings.ToLower(domain), "."))
+	return suffix
+}
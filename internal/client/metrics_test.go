@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type clierr struct{ err error }
+
+func (c clierr) Lookup(_ context.Context, domain string, host string) (Result, error) {
+	return Result{}, c.err
+}
+
+func TestInstrument(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewMetrics()
+	rdap := metrics.Instrument("rdap", clierr{fmt.Errorf("%w: 404", ErrDomainNotFound)})
+	whois := metrics.Instrument("whois", clierr{context.DeadlineExceeded})
+	ok := metrics.Instrument("whois", clisuccess(time.Now()))
+
+	_, err := NewMultiClient(rdap, whois).Lookup(ctx, "foo.com", "")
+	require.ErrorIs(t, err, ErrTimeout)
+	require.Equal(t, "timeout", Reason(err))
+	_, err = rdap.Lookup(ctx, "bar.co.uk", "")
+	require.ErrorIs(t, err, ErrDomainNotFound)
+	result, err := ok.Lookup(ctx, "FOO.COM", "")
+	require.NoError(t, err)
+	require.Equal(t, "whois", result.Backend)
+
+	require.Equal(t, 3, testutil.CollectAndCount(metrics, "domain_backend_request_duration_seconds"))
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP domain_backend_requests_total total of requests to each backend by TLD, result being success or the error reason
+# TYPE domain_backend_requests_total counter
+domain_backend_requests_total{backend="rdap",result="not_found",tld="co.uk"} 1
+domain_backend_requests_total{backend="rdap",result="not_found",tld="com"} 1
+domain_backend_requests_total{backend="whois",result="success",tld="com"} 1
+domain_backend_requests_total{backend="whois",result="timeout",tld="com"} 1
+`), "domain_backend_requests_total"))
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP domain_backend_errors_total total of errors returned by each backend, by reason
+# TYPE domain_backend_errors_total counter
+domain_backend_errors_total{backend="rdap",reason="not_found"} 2
+domain_backend_errors_total{backend="whois",reason="timeout"} 1
+`), "domain_backend_errors_total"))
+}
+
+// clidone closes done once the wrapped client returns.
+type clidone struct {
+	client Client
+	done   chan struct{}
+}
+
+func (c clidone) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	defer close(c.done)
+	return c.client.Lookup(ctx, domain, host)
+}
+
+func TestInstrumentRaceLoser(t *testing.T) {
+	metrics := NewMetrics()
+	loser := clidone{client: metrics.Instrument("whois", make(cliblock)), done: make(chan struct{})}
+	winner := metrics.Instrument("rdap", clisuccess(time.Now()))
+
+	result, err := NewStrategyClient(StrategyRace, loser, winner).Lookup(context.Background(), "foo.com", "")
+	require.NoError(t, err)
+	require.Equal(t, "rdap", result.Backend)
+	select {
+	case <-loser.done:
+	case <-time.After(time.Second):
+		t.Fatal("loser was not canceled")
+	}
+
+	require.Equal(t, 0, testutil.CollectAndCount(metrics, "domain_backend_errors_total"))
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP domain_backend_requests_total total of requests to each backend by TLD, result being success or the error reason
+# TYPE domain_backend_requests_total counter
+domain_backend_requests_total{backend="rdap",result="success",tld="com"} 1
+`), "domain_backend_requests_total"))
+}
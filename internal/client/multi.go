@@ -2,25 +2,149 @@ package client
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 )
 
+// Strategy is how a multi client uses its clients.
+type Strategy string
+
+// Strategies supported by NewStrategyClient.
+const (
+	// StrategySequential tries the clients one after the other.
+	StrategySequential Strategy = "sequential"
+	// StrategyRace queries all clients at once, using the first success.
+	StrategyRace Strategy = "race"
+	// StrategyConsensus queries all clients at once and compares their
+	// results.
+	StrategyConsensus Strategy = "consensus"
+)
+
+// consensusTolerance is how far apart the expiry dates of two clients may
+// be without being considered a disagreement, e.g. due to timezones.
+const consensusTolerance = 24 * time.Hour
+
+// errRaceLost is the cause of the cancellation of the lookups that lost a
+// race, so their outcome is not recorded as a failure.
+var errRaceLost = errors.New("another backend won the race") // nolint: gochecknoglobals
+
+// MultiError holds the errors of all the clients of a multi client.
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of each client, so errors.Is and errors.As
+// match any of them.
+func (errs MultiError) Unwrap() []error {
+	return errs
+}
+
+// NewStrategyClient returns a client that wraps multiple clients, using
+// them according to the given strategy.
+func NewStrategyClient(strategy Strategy, clients ...Client) Client {
+	switch strategy {
+	case StrategyRace:
+		return raceClient(clients)
+	case StrategyConsensus:
+		return consensusClient(clients)
+	default:
+		return multiClient(clients)
+	}
+}
+
 type multiClient []Client
 
-func (clients multiClient) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
-	var t time.Time
-	var err error
+func (clients multiClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	var errs MultiError
 	for _, client := range clients {
-		t, err = client.ExpireTime(ctx, domain, host)
+		result, err := client.Lookup(ctx, domain, host)
 		if err == nil {
-			break
+			return result, nil
 		}
+		errs = append(errs, err)
 	}
-	return t, err
+	return Result{}, errs
 }
 
 // NewMultiClient returns a client that wraps multiple clients.
-// It returns the first success, or, if all clients fail, the latest failure.
+// It returns the first success, or, if all clients fail, a MultiError with
+// all the failures.
 func NewMultiClient(clients ...Client) Client {
 	return multiClient(clients)
 }
+
+type lookup struct {
+	index  int
+	result Result
+	err    error
+}
+
+// lookupAll queries all clients concurrently, sending the outcomes to the
+// returned channel as they finish.
+func lookupAll(ctx context.Context, clients []Client, domain, host string) <-chan lookup {
+	ch := make(chan lookup, len(clients))
+	for i, client := range clients {
+		go func() {
+			result, err := client.Lookup(ctx, domain, host)
+			ch <- lookup{index: i, result: result, err: err}
+		}()
+	}
+	return ch
+}
+
+type raceClient []Client
+
+func (clients raceClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errRaceLost)
+
+	errs := make([]error, len(clients))
+	ch := lookupAll(ctx, clients, domain, host)
+	for range clients {
+		l := <-ch
+		if l.err == nil {
+			return l.result, nil
+		}
+		errs[l.index] = l.err
+	}
+	return Result{}, MultiError(errs)
+}
+
+type consensusClient []Client
+
+func (clients consensusClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	lookups := make([]lookup, len(clients))
+	ch := lookupAll(ctx, clients, domain, host)
+	for range clients {
+		l := <-ch
+		lookups[l.index] = l
+	}
+
+	var errs MultiError
+	var results []Result
+	for _, l := range lookups {
+		if l.err != nil {
+			errs = append(errs, l.err)
+			continue
+		}
+		results = append(results, l.result)
+	}
+	if len(results) == 0 {
+		return Result{}, errs
+	}
+
+	result := results[0]
+	for _, other := range results[1:] {
+		if diff := result.Expiry.Sub(other.Expiry).Abs(); diff > consensusTolerance {
+			result.Disagreement = true
+		}
+	}
+	return result, nil
+}
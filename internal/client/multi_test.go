@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -11,33 +12,96 @@ import (
 
 type clifail int
 
-func (clifail) ExpireTime(_ context.Context, domain string, host string) (time.Time, error) {
-	return time.Time{}, errors.New("foo")
+func (clifail) Lookup(_ context.Context, domain string, host string) (Result, error) {
+	return Result{}, errors.New("foo")
 }
 
 type clisuccess time.Time
 
-func (c clisuccess) ExpireTime(_ context.Context, domain string, host string) (time.Time, error) {
-	return time.Time(c), nil
+func (c clisuccess) Lookup(_ context.Context, domain string, host string) (Result, error) {
+	return Result{Expiry: time.Time(c)}, nil
 }
 
 func TestMulti(t *testing.T) {
 	ctx := context.Background()
 	t.Run("first client succeed", func(t *testing.T) {
 		expected := time.Now()
-		expire, err := NewMultiClient(clisuccess(expected), clifail(0)).ExpireTime(ctx, "a", "")
+		expire, err := NewMultiClient(clisuccess(expected), clifail(0)).Lookup(ctx, "a", "")
 		require.NoError(t, err)
-		require.Equal(t, expected, expire)
+		require.Equal(t, expected, expire.Expiry)
 	})
 	t.Run("last client succeed", func(t *testing.T) {
 		expected := time.Now()
-		expire, err := NewMultiClient(clifail(0), clifail(0), clisuccess(expected)).ExpireTime(ctx, "a", "")
+		expire, err := NewMultiClient(clifail(0), clifail(0), clisuccess(expected)).Lookup(ctx, "a", "")
 		require.NoError(t, err)
-		require.Equal(t, expected, expire)
+		require.Equal(t, expected, expire.Expiry)
 	})
 	t.Run("no client succeed", func(t *testing.T) {
-		expire, err := NewMultiClient(clifail(0), clifail(0), clifail(0)).ExpireTime(ctx, "a", "")
-		require.EqualError(t, err, "foo")
-		require.Equal(t, expire, time.Time{})
+		expire, err := NewMultiClient(clifail(0), clifail(0), clifail(0)).Lookup(ctx, "a", "")
+		require.EqualError(t, err, "foo; foo; foo")
+		require.Len(t, err.(MultiError), 3)
+		require.Equal(t, expire, Result{})
+	})
+	t.Run("keeps every error", func(t *testing.T) {
+		_, err := NewMultiClient(clierr{fmt.Errorf("%w: 404", ErrDomainNotFound)}, clierr{ErrTimeout}).Lookup(ctx, "a", "")
+		require.ErrorIs(t, err, ErrDomainNotFound)
+		require.ErrorIs(t, err, ErrTimeout)
+	})
+}
+
+// cliblock blocks until its context is done, recording that it was.
+type cliblock chan struct{}
+
+func (c cliblock) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	<-ctx.Done()
+	close(c)
+	return Result{}, ctx.Err()
+}
+
+func TestRace(t *testing.T) {
+	ctx := context.Background()
+	t.Run("first success wins", func(t *testing.T) {
+		expected := time.Now()
+		loser := make(cliblock)
+		result, err := NewStrategyClient(StrategyRace, loser, clifail(0), clisuccess(expected)).Lookup(ctx, "a", "")
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Expiry)
+		select {
+		case <-loser:
+		case <-time.After(time.Second):
+			t.Fatal("loser was not canceled")
+		}
+	})
+	t.Run("no client succeed", func(t *testing.T) {
+		_, err := NewStrategyClient(StrategyRace, clierr{errors.New("a")}, clierr{errors.New("b")}).Lookup(ctx, "a", "")
+		require.EqualError(t, err, "a; b")
+	})
+}
+
+func TestConsensus(t *testing.T) {
+	ctx := context.Background()
+	expected := time.Now()
+	t.Run("agree", func(t *testing.T) {
+		result, err := NewStrategyClient(StrategyConsensus, clisuccess(expected), clisuccess(expected.Add(time.Hour))).Lookup(ctx, "a", "")
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Expiry)
+		require.False(t, result.Disagreement)
+	})
+	t.Run("disagree", func(t *testing.T) {
+		result, err := NewStrategyClient(StrategyConsensus, clisuccess(expected), clisuccess(expected.AddDate(1, 0, 0))).Lookup(ctx, "a", "")
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Expiry)
+		require.True(t, result.Disagreement)
+	})
+	t.Run("one fails", func(t *testing.T) {
+		result, err := NewStrategyClient(StrategyConsensus, clifail(0), clisuccess(expected)).Lookup(ctx, "a", "")
+		require.NoError(t, err)
+		require.Equal(t, expected, result.Expiry)
+		require.False(t, result.Disagreement)
+	})
+	t.Run("no client succeed", func(t *testing.T) {
+		_, err := NewStrategyClient(StrategyConsensus, clifail(0), clierr{ErrTimeout}).Lookup(ctx, "a", "")
+		require.EqualError(t, err, "foo; timeout")
+		require.ErrorIs(t, err, ErrTimeout)
 	})
 }
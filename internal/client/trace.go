@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Trace records the details of a lookup, such as the raw responses and
+// the date layouts used, for debugging.
+type Trace struct {
+	mutex  sync.Mutex
+	events []TraceEvent
+}
+
+// TraceEvent is a step of a lookup.
+type TraceEvent struct {
+	Backend string
+	Step    string
+	Value   string
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context whose lookups are recorded in the trace.
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFrom returns the trace of the context, or nil if it has none.
+func TraceFrom(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// Add records a step of a lookup. It does nothing on a nil trace, so
+// callers don't need to check whether tracing is on.
+func (t *Trace) Add(backend, step, value string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events = append(t.events, TraceEvent{
+		Backend: backend,
+		Step:    step,
+		Value:   value,
+	})
+}
+
+// Events returns the recorded steps.
+func (t *Trace) Events() []TraceEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]TraceEvent(nil), t.events...)
+}
+
+// String returns the recorded steps as text, one per line, with multiline
+// values such as responses indented below their step.
+func (t *Trace) String() string {
+	var sb strings.Builder
+	for _, event := range t.Events() {
+		value := strings.TrimRight(event.Value, "\r\n")
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&sb, "[%s] %s: %s\n", event.Backend, event.Step, value)
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s] %s:\n", event.Backend, event.Step)
+		for line := range strings.SplitSeq(value, "\n") {
+			fmt.Fprintf(&sb, "\t%s\n", strings.TrimRight(line, "\r"))
+		}
+	}
+	return sb.String()
+}
+
+// NewTracedClient returns a client that records the lookups of each domain
+// by the given client in the trace of the domain, so concurrent lookups of
+// different domains don't get mixed up. Domains without a trace are not
+// recorded.
+func NewTracedClient(client Client, traces map[string]*Trace) Client {
+	return tracedClient{
+		client: client,
+		traces: traces,
+	}
+}
+
+type tracedClient struct {
+	client Client
+	traces map[string]*Trace
+}
+
+func (c tracedClient) Lookup(ctx context.Context, domain string, host string) (Result, error) {
+	return c.client.Lookup(WithTrace(ctx, c.traces[domain]), domain, host)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	require.Nil(t, TraceFrom(context.Background()))
+	var none *Trace
+	none.Add("whois", "request", "ignored")
+
+	trace := &Trace{}
+	ctx := WithTrace(context.Background(), trace)
+	TraceFrom(ctx).Add("whois", "request", "whois.foo")
+	TraceFrom(ctx).Add("whois", "response", "Domain: foo.com\r\nExpiry: 2030-01-02\r\n")
+	require.Len(t, trace.Events(), 2)
+	require.Equal(t, "[whois] request: whois.foo\n[whois] response:\n\tDomain: foo.com\n\tExpiry: 2030-01-02\n", trace.String())
+}
+
+type tracing struct{}
+
+func (tracing) Lookup(ctx context.Context, domain string, _ string) (Result, error) {
+	TraceFrom(ctx).Add("fake", "request", domain)
+	return Result{}, nil
+}
+
+func TestTracedClient(t *testing.T) {
+	foo, bar := &Trace{}, &Trace{}
+	cli := NewTracedClient(tracing{}, map[string]*Trace{"foo.com": foo, "bar.com": bar})
+	for _, domain := range []string{"foo.com", "bar.com", "other.com"} {
+		_, err := cli.Lookup(context.Background(), domain, "")
+		require.NoError(t, err)
+	}
+	require.Equal(t, []TraceEvent{{Backend: "fake", Step: "request", Value: "foo.com"}}, foo.Events())
+	require.Equal(t, []TraceEvent{{Backend: "fake", Step: "request", Value: "bar.com"}}, bar.Events())
+}
@@ -12,31 +12,63 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// concurrency is how many domains are probed at once.
+const concurrency = 10
+
 type domainCollector struct {
-	mutex   sync.Mutex
+	mutex sync.Mutex
+	// ctx is the parent of the probes, e.g. the request of /probe.
+	ctx     context.Context
 	client  client.Client
 	domains []safeconfig.Domain
 	timeout time.Duration
+	// legacyExpiryDays makes failed probes export domain_expiry_days as -1,
+	// as older versions did, instead of leaving it out.
+	legacyExpiryDays bool
+
+	expiryDays      *prometheus.Desc
+	expirySeconds   *prometheus.Desc
+	probeSuccess    *prometheus.Desc
+	probeDuration   *prometheus.Desc
+	parseConfidence *prometheus.Desc
+	whoisServer     *prometheus.Desc
+	expiryTimestamp *prometheus.Desc
+	registered      *prometheus.Desc
+	probeFailure    *prometheus.Desc
+	disagreement    *prometheus.Desc
+	warningDays     *prometheus.Desc
+	criticalDays    *prometheus.Desc
+}
 
-	expiryDays    *prometheus.Desc
-	probeSuccess  *prometheus.Desc
-	probeDuration *prometheus.Desc
+// NewDomainCollector returns a domain collector, which probes the domains
+// concurrently, each one within the given timeout, and stops when ctx is
+// done. If legacyExpiryDays is set, failed probes export domain_expiry_days
+// as -1, as older versions did.
+func NewDomainCollector(ctx context.Context, client client.Client, timeout time.Duration, legacyExpiryDays bool, domains ...safeconfig.Domain) prometheus.Collector {
+	return newDomainCollector(ctx, client, timeout, legacyExpiryDays, domains...)
 }
 
-// NewDomainCollector returns a domain collector.
-func NewDomainCollector(client client.Client, timeout time.Duration, domains ...safeconfig.Domain) prometheus.Collector {
+func newDomainCollector(ctx context.Context, client client.Client, timeout time.Duration, legacyExpiryDays bool, domains ...safeconfig.Domain) *domainCollector {
 	const namespace = "domain"
 	const subsystem = ""
 	return &domainCollector{
-		client:  client,
-		domains: domains,
-		timeout: timeout,
+		ctx:              ctx,
+		client:           client,
+		domains:          domains,
+		timeout:          timeout,
+		legacyExpiryDays: legacyExpiryDays,
 		expiryDays: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "expiry_days"),
 			"time in days until the domain expires",
 			[]string{"domain"},
 			nil,
 		),
+		expirySeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "expiry_seconds"),
+			"time in seconds until the domain expires",
+			[]string{"domain"},
+			nil,
+		),
 		probeSuccess: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "probe_success"),
 			"whether the probe was successful or not",
@@ -49,14 +81,71 @@ func NewDomainCollector(client client.Client, timeout time.Duration, domains ...
 			[]string{"domain"},
 			nil,
 		),
+		parseConfidence: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "parse_confidence"),
+			"how confident the parser is about the expiry date, 1 being an exact match",
+			[]string{"domain"},
+			nil,
+		),
+		whoisServer: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "whois_server_info"),
+			"the whois server whose answer was used",
+			[]string{"domain", "whois_server"},
+			nil,
+		),
+		expiryTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "expiry_timestamp_seconds"),
+			"expiry date of the domain in unix seconds, as reported by each source or the effective one",
+			[]string{"domain", "source"},
+			nil,
+		),
+		registered: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "registered"),
+			"whether the domain is registered or not, absent if unknown",
+			[]string{"domain"},
+			nil,
+		),
+		probeFailure: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "probe_failure"),
+			"why the probe failed, present only on failures",
+			[]string{"domain", "reason"},
+			nil,
+		),
+		disagreement: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "backend_disagreement"),
+			"whether the backends returned different expiry dates, consensus strategy only",
+			[]string{"domain"},
+			nil,
+		),
+		warningDays: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "expiry_warning_threshold_days"),
+			"days before expiring the domain needs attention",
+			[]string{"domain"},
+			nil,
+		),
+		criticalDays: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "expiry_critical_threshold_days"),
+			"days before expiring the domain needs urgent attention",
+			[]string{"domain"},
+			nil,
+		),
 	}
 }
 
 // Describe all metrics
 func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.expiryDays
+	ch <- c.expirySeconds
 	ch <- c.probeDuration
 	ch <- c.probeSuccess
+	ch <- c.parseConfidence
+	ch <- c.whoisServer
+	ch <- c.expiryTimestamp
+	ch <- c.registered
+	ch <- c.probeFailure
+	ch <- c.disagreement
+	ch <- c.warningDays
+	ch <- c.criticalDays
 }
 
 // Collect all metrics
@@ -64,36 +153,141 @@ func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, domain := range c.domains {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			c.collect(ch, domain)
+		})
+	}
+	wg.Wait()
+}
+
+// collect probes a single domain.
+func (c *domainCollector) collect(ch chan<- prometheus.Metric, domain safeconfig.Domain) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
 	defer cancel()
 
-	for _, domain := range c.domains {
-		start := time.Now()
-		date, err := c.client.ExpireTime(ctx, domain.Name, domain.Host)
-		if err != nil {
-			log.Error().Err(err).Msgf("failed to probe %s", domain)
-		}
+	start := time.Now()
+	result, err := c.client.Lookup(ctx, domain.Name, domain.Host)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to probe %v", domain)
+	}
+	c.writeThresholds(ch, domain)
+	c.write(ch, domain.Name, result, err, time.Since(start))
+}
+
+// writeThresholds sends the thresholds of the domain.
+func (c *domainCollector) writeThresholds(ch chan<- prometheus.Metric, domain safeconfig.Domain) {
+	warning, critical := domain.Thresholds()
+	ch <- prometheus.MustNewConstMetric(
+		c.warningDays,
+		prometheus.GaugeValue,
+		float64(warning),
+		domain.Name,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.criticalDays,
+		prometheus.GaugeValue,
+		float64(critical),
+		domain.Name,
+	)
+}
 
-		success := err == nil
+// write sends the metrics of a probe of the domain that took the given
+// duration.
+func (c *domainCollector) write(ch chan<- prometheus.Metric, domain string, result client.Result, err error, duration time.Duration) {
+	if err != nil {
 		ch <- prometheus.MustNewConstMetric(
-			c.probeSuccess,
+			c.probeFailure,
 			prometheus.GaugeValue,
-			boolToFloat(success),
-			domain.Name,
+			1,
+			domain,
+			client.Reason(client.Classify(err)),
 		)
+		if c.legacyExpiryDays {
+			ch <- prometheus.MustNewConstMetric(
+				c.expiryDays,
+				prometheus.GaugeValue,
+				-1,
+				domain,
+			)
+		}
+	} else {
 		ch <- prometheus.MustNewConstMetric(
 			c.expiryDays,
 			prometheus.GaugeValue,
-			math.Floor(time.Until(date).Hours()/24),
-			domain.Name,
+			math.Floor(time.Until(result.Expiry).Hours()/24),
+			domain,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.expirySeconds,
+			prometheus.GaugeValue,
+			time.Until(result.Expiry).Seconds(),
+			domain,
 		)
 		ch <- prometheus.MustNewConstMetric(
-			c.probeDuration,
+			c.parseConfidence,
 			prometheus.GaugeValue,
-			time.Since(start).Seconds(),
-			domain.Name,
+			result.Confidence,
+			domain,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.disagreement,
+			prometheus.GaugeValue,
+			boolToFloat(result.Disagreement),
+			domain,
+		)
+		for source, date := range map[string]time.Time{
+			"registry":  result.RegistryExpiry,
+			"registrar": result.RegistrarExpiry,
+			"effective": result.Expiry,
+		} {
+			if date.IsZero() {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				c.expiryTimestamp,
+				prometheus.GaugeValue,
+				float64(date.Unix()),
+				domain,
+				source,
+			)
+		}
+		if result.WhoisServer != "" {
+			ch <- prometheus.MustNewConstMetric(
+				c.whoisServer,
+				prometheus.GaugeValue,
+				1,
+				domain,
+				result.WhoisServer,
+			)
+		}
+	}
+
+	success := err == nil
+	if success || client.IsNotFound(err) {
+		ch <- prometheus.MustNewConstMetric(
+			c.registered,
+			prometheus.GaugeValue,
+			boolToFloat(success),
+			domain,
 		)
 	}
+	ch <- prometheus.MustNewConstMetric(
+		c.probeSuccess,
+		prometheus.GaugeValue,
+		boolToFloat(success),
+		domain,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.probeDuration,
+		prometheus.GaugeValue,
+		duration.Seconds(),
+		domain,
+	)
 }
 
 func boolToFloat(b bool) float64 {
@@ -1,6 +1,9 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -19,10 +22,30 @@ import (
 
 func TestCollectorError(t *testing.T) {
 	multi := client.NewMultiClient(rdap.NewClient(), whois.NewClient())
-	testCollector(t, NewDomainCollector(multi, time.Second, safeconfig.Domain{Name: "fake.foo", Host: ""}), func(t *testing.T, status int, body string) {
+	testCollector(t, NewDomainCollector(context.Background(), multi, time.Second, false, safeconfig.Domain{Name: "fake.foo", Host: ""}), func(t *testing.T, status int, body string) {
 		require.Equal(t, 200, status)
 		require.Contains(t, body, "domain_probe_success{domain=\"fake.foo\"} 0")
-		require.Contains(t, body, "domain_expiry_days{domain=\"fake.foo\"} -1")
+		require.NotContains(t, body, "domain_expiry_days{")
+		require.NotContains(t, body, "domain_expiry_seconds{")
+		require.NotContains(t, body, "domain_expiry_timestamp_seconds{")
+	})
+}
+
+func TestLegacyExpiryDays(t *testing.T) {
+	cli := fakeClient{err: client.ErrTimeout}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, true, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_expiry_days{domain=\"foo.com\"} -1")
+		require.NotContains(t, body, "domain_expiry_seconds{")
+	})
+}
+
+func TestExpirySeconds(t *testing.T) {
+	cli := fakeClient{result: client.Result{Expiry: time.Now().Add(36 * time.Hour)}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_expiry_days{domain=\"foo.com\"} 1")
+		require.Regexp(t, `domain_expiry_seconds{domain="foo.com"} 1295\d\d\.\d+`, body)
 	})
 }
 
@@ -30,7 +53,7 @@ func TestNotExpired(t *testing.T) {
 	multi := client.NewMultiClient(rdap.NewClient(), whois.NewClient())
 	testCollector(
 		t,
-		NewDomainCollector(multi, time.Second, safeconfig.Domain{Name: "goreleaser.com", Host: ""}),
+		NewDomainCollector(context.Background(), multi, time.Second, false, safeconfig.Domain{Name: "goreleaser.com", Host: ""}),
 		func(t *testing.T, status int, body string) {
 			t.Log(body)
 			if strings.Contains(body, "domain_probe_success{domain=\"goreleaser.com\"} 0") {
@@ -44,6 +67,192 @@ func TestNotExpired(t *testing.T) {
 	)
 }
 
+type fakeClient struct {
+	result client.Result
+	err    error
+}
+
+func (f fakeClient) Lookup(_ context.Context, _ string, _ string) (client.Result, error) {
+	return f.result, f.err
+}
+
+func TestParseConfidence(t *testing.T) {
+	cli := fakeClient{result: client.Result{Expiry: time.Now().Add(48 * time.Hour), Confidence: 0.5}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_probe_success{domain=\"foo.com\"} 1")
+		require.Contains(t, body, "domain_parse_confidence{domain=\"foo.com\"} 0.5")
+		require.NotContains(t, body, "domain_whois_server_info")
+	})
+
+	cli = fakeClient{err: errors.New("fail")}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_probe_success{domain=\"foo.com\"} 0")
+		require.NotContains(t, body, "domain_parse_confidence")
+	})
+}
+
+func TestWhoisServer(t *testing.T) {
+	cli := fakeClient{result: client.Result{Expiry: time.Now().Add(48 * time.Hour), WhoisServer: "whois.foo"}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_whois_server_info{domain=\"foo.com\",whois_server=\"whois.foo\"} 1")
+	})
+}
+
+func TestExpiryTimestamp(t *testing.T) {
+	cli := fakeClient{result: client.Result{
+		Expiry:          time.Unix(1900000000, 0),
+		RegistryExpiry:  time.Unix(1900000000, 0),
+		RegistrarExpiry: time.Unix(1930000000, 0),
+	}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_expiry_timestamp_seconds{domain=\"foo.com\",source=\"registry\"} 1.9e+09")
+		require.Contains(t, body, "domain_expiry_timestamp_seconds{domain=\"foo.com\",source=\"registrar\"} 1.93e+09")
+		require.Contains(t, body, "domain_expiry_timestamp_seconds{domain=\"foo.com\",source=\"effective\"} 1.9e+09")
+	})
+
+	cli = fakeClient{result: client.Result{Expiry: time.Unix(1900000000, 0)}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_expiry_timestamp_seconds{domain=\"foo.com\",source=\"effective\"} 1.9e+09")
+		require.NotContains(t, body, "source=\"registry\"")
+		require.NotContains(t, body, "source=\"registrar\"")
+	})
+}
+
+func TestRegistered(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		cli    fakeClient
+		expect string
+	}{
+		{
+			name:   "registered",
+			cli:    fakeClient{result: client.Result{Expiry: time.Now().Add(48 * time.Hour)}},
+			expect: "domain_registered{domain=\"foo.com\"} 1",
+		},
+		{
+			name:   "not found",
+			cli:    fakeClient{err: fmt.Errorf("%w: No match", client.ErrDomainNotFound)},
+			expect: "domain_registered{domain=\"foo.com\"} 0",
+		},
+		{
+			name:   "not found by all backends",
+			cli:    fakeClient{err: client.MultiError{fmt.Errorf("%w: 404", client.ErrDomainNotFound), fmt.Errorf("%w: No match", client.ErrDomainNotFound)}},
+			expect: "domain_registered{domain=\"foo.com\"} 0",
+		},
+		{
+			name: "not found by one backend",
+			cli:  fakeClient{err: client.MultiError{fmt.Errorf("%w: 404", client.ErrDomainNotFound), client.ErrTimeout}},
+		},
+		{
+			name: "unknown",
+			cli:  fakeClient{err: errors.New("timeout")},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testCollector(t, NewDomainCollector(context.Background(), tt.cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				if tt.expect == "" {
+					require.NotContains(t, body, "domain_registered{")
+					return
+				}
+				require.Contains(t, body, tt.expect)
+			})
+		})
+	}
+}
+
+func TestDisagreement(t *testing.T) {
+	cli := fakeClient{result: client.Result{Expiry: time.Now().Add(48 * time.Hour), Disagreement: true}}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_backend_disagreement{domain=\"foo.com\"} 1")
+	})
+}
+
+func TestProbeFailure(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		cli    fakeClient
+		expect string
+	}{
+		{
+			name:   "timeout",
+			cli:    fakeClient{err: fmt.Errorf("failed to fetch: %w", context.DeadlineExceeded)},
+			expect: "domain_probe_failure{domain=\"foo.com\",reason=\"timeout\"} 1",
+		},
+		{
+			name:   "bad date",
+			cli:    fakeClient{err: fmt.Errorf("%w: could not parse date: \"soon\"", client.ErrBadDate)},
+			expect: "domain_probe_failure{domain=\"foo.com\",reason=\"parse_bad_date\"} 1",
+		},
+		{
+			name:   "unknown",
+			cli:    fakeClient{err: errors.New("fail")},
+			expect: "domain_probe_failure{domain=\"foo.com\",reason=\"unknown\"} 1",
+		},
+		{
+			name: "success",
+			cli:  fakeClient{result: client.Result{Expiry: time.Now().Add(48 * time.Hour)}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testCollector(t, NewDomainCollector(context.Background(), tt.cli, time.Second, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+				require.Equal(t, 200, status)
+				if tt.expect == "" {
+					require.NotContains(t, body, "domain_probe_failure{")
+					return
+				}
+				require.Contains(t, body, tt.expect)
+			})
+		})
+	}
+}
+
+type slowClient time.Duration
+
+func (s slowClient) Lookup(ctx context.Context, _ string, _ string) (client.Result, error) {
+	select {
+	case <-time.After(time.Duration(s)):
+		return client.Result{Expiry: time.Now().Add(48 * time.Hour)}, nil
+	case <-ctx.Done():
+		return client.Result{}, ctx.Err()
+	}
+}
+
+func TestConcurrentCollect(t *testing.T) {
+	var domains []safeconfig.Domain
+	for i := range 3 * concurrency {
+		domains = append(domains, safeconfig.Domain{Name: fmt.Sprintf("foo%d.com", i)})
+	}
+
+	start := time.Now()
+	testCollector(t, NewDomainCollector(context.Background(), slowClient(100*time.Millisecond), time.Second, false, domains...), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		for _, domain := range domains {
+			require.Contains(t, body, fmt.Sprintf("domain_probe_success{domain=%q} 1", domain.Name))
+		}
+	})
+	require.Less(t, time.Since(start), 3*concurrency*100*time.Millisecond/2)
+}
+
+func TestCollectorContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	testCollector(t, NewDomainCollector(ctx, slowClient(time.Minute), time.Minute, false, safeconfig.Domain{Name: "foo.com"}), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_probe_success{domain=\"foo.com\"} 0")
+		require.Contains(t, body, "domain_probe_failure{domain=\"foo.com\",reason=\"timeout\"} 1")
+	})
+	require.Less(t, time.Since(start), time.Second)
+}
+
 func testCollector(t *testing.T, collector prometheus.Collector, checker func(t *testing.T, status int, body string)) {
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collector)
@@ -57,3 +266,18 @@ func testCollector(t *testing.T, collector prometheus.Collector, checker func(t
 	require.NoError(t, err)
 	checker(t, resp.StatusCode, string(body))
 }
+
+func TestThresholds(t *testing.T) {
+	cli := fakeClient{err: client.ErrTimeout}
+	domains := []safeconfig.Domain{
+		{Name: "foo.com"},
+		{Name: "bar.com", WarningDays: 60, CriticalDays: 14},
+	}
+	testCollector(t, NewDomainCollector(context.Background(), cli, time.Second, false, domains...), func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_expiry_warning_threshold_days{domain=\"foo.com\"} 30")
+		require.Contains(t, body, "domain_expiry_critical_threshold_days{domain=\"foo.com\"} 7")
+		require.Contains(t, body, "domain_expiry_warning_threshold_days{domain=\"bar.com\"} 60")
+		require.Contains(t, body, "domain_expiry_critical_threshold_days{domain=\"bar.com\"} 14")
+	})
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Statuser returns the status of a domain, as last seen by the refresher.
+type Statuser interface {
+	Status(name string) (refresher.Status, bool)
+}
+
+type snapshotCollector struct {
+	*domainCollector
+	statuser Statuser
+
+	probePending *prometheus.Desc
+}
+
+// NewSnapshotCollector returns a domain collector that never probes the
+// domains, exposing the last results of the refresher instead, so scrapes
+// never block. legacyExpiryDays is as in NewDomainCollector.
+func NewSnapshotCollector(statuser Statuser, legacyExpiryDays bool, domains ...safeconfig.Domain) prometheus.Collector {
+	return &snapshotCollector{
+		domainCollector: newDomainCollector(context.Background(), nil, 0, legacyExpiryDays, domains...),
+		statuser:        statuser,
+		probePending: prometheus.NewDesc(
+			"domain_probe_pending",
+			"whether the domain was not probed by the refresher yet",
+			[]string{"domain"},
+			nil,
+		),
+	}
+}
+
+// Describe all metrics
+func (c *snapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.domainCollector.Describe(ch)
+	ch <- c.probePending
+}
+
+// Collect all metrics
+func (c *snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, domain := range c.domains {
+		c.writeThresholds(ch, domain)
+		status, _ := c.statuser.Status(domain.Name)
+		pending := status.LastAttempt.IsZero()
+		ch <- prometheus.MustNewConstMetric(
+			c.probePending,
+			prometheus.GaugeValue,
+			boolToFloat(pending),
+			domain.Name,
+		)
+		if pending {
+			continue
+		}
+		c.write(ch, domain.Name, status.Result, status.LastError, status.Duration)
+	}
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStatuser map[string]refresher.Status
+
+func (f fakeStatuser) Status(name string) (refresher.Status, bool) {
+	status, ok := f[name]
+	return status, ok
+}
+
+func TestSnapshotCollector(t *testing.T) {
+	statuser := fakeStatuser{
+		"ok.com": {
+			Domain:      safeconfig.Domain{Name: "ok.com"},
+			Result:      client.Result{Expiry: time.Now().Add(50 * time.Hour)},
+			LastAttempt: time.Now(),
+			Duration:    2 * time.Second,
+		},
+		"fail.com": {
+			Domain:      safeconfig.Domain{Name: "fail.com"},
+			LastAttempt: time.Now(),
+			LastError:   client.ErrTimeout,
+		},
+	}
+	collector := NewSnapshotCollector(statuser, false,
+		safeconfig.Domain{Name: "ok.com"},
+		safeconfig.Domain{Name: "fail.com"},
+		safeconfig.Domain{Name: "pending.com"},
+	)
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Equal(t, 200, status)
+		require.Contains(t, body, "domain_probe_pending{domain=\"ok.com\"} 0")
+		require.Contains(t, body, "domain_probe_success{domain=\"ok.com\"} 1")
+		require.Contains(t, body, "domain_expiry_days{domain=\"ok.com\"} 2")
+		require.Contains(t, body, "domain_probe_duration_seconds{domain=\"ok.com\"} 2")
+
+		require.Contains(t, body, "domain_probe_pending{domain=\"fail.com\"} 0")
+		require.Contains(t, body, "domain_probe_success{domain=\"fail.com\"} 0")
+		require.Contains(t, body, "domain_probe_failure{domain=\"fail.com\",reason=\"timeout\"} 1")
+		require.NotContains(t, body, "domain_expiry_days{domain=\"fail.com\"}")
+
+		require.Contains(t, body, "domain_probe_pending{domain=\"pending.com\"} 1")
+		require.Contains(t, body, "domain_expiry_warning_threshold_days{domain=\"pending.com\"} 30")
+		require.NotContains(t, body, "domain_probe_success{domain=\"pending.com\"}")
+	})
+}
+
+func TestSnapshotCollectorRefresher(t *testing.T) {
+	domain := safeconfig.Domain{Name: "foo.com"}
+	fresh := refresher.New(time.Minute, fakeClient{err: errors.New("foo")}, time.Second, domain)
+	defer fresh.Stop()
+	collector := NewSnapshotCollector(fresh, false, domain)
+
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Contains(t, body, "domain_probe_pending{domain=\"foo.com\"} 1")
+	})
+
+	fresh.Refresh(context.Background())
+	testCollector(t, collector, func(t *testing.T, status int, body string) {
+		require.Contains(t, body, "domain_probe_pending{domain=\"foo.com\"} 0")
+		require.Contains(t, body, "domain_probe_success{domain=\"foo.com\"} 0")
+	})
+}
@@ -0,0 +1,124 @@
+// Package dateparse parses the many date formats used by registries.
+package dateparse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// nolint: gochecknoglobals
+var (
+	// abbreviations maps zone abbreviations used by registries to their
+	// offset, as Go can only resolve the ones known to the location.
+	abbreviations = map[string]int{
+		"UTC":  0,
+		"GMT":  0,
+		"WET":  0,
+		"WEST": 1 * 3600,
+		"BST":  1 * 3600,
+		"CET":  1 * 3600,
+		"CEST": 2 * 3600,
+		"EET":  2 * 3600,
+		"EEST": 3 * 3600,
+		"MSK":  3 * 3600,
+		"ICT":  7 * 3600,
+		"WIB":  7 * 3600,
+		"HKT":  8 * 3600,
+		"SGT":  8 * 3600,
+		"AWST": 8 * 3600,
+		"JST":  9 * 3600,
+		"KST":  9 * 3600,
+		"AEST": 10 * 3600,
+		"AEDT": 11 * 3600,
+		"NZST": 12 * 3600,
+		"NZDT": 13 * 3600,
+		"CLT":  -4 * 3600,
+		"CLST": -3 * 3600,
+		"BRT":  -3 * 3600,
+		"EST":  -5 * 3600,
+		"EDT":  -4 * 3600,
+		"PST":  -8 * 3600,
+		"PDT":  -7 * 3600,
+	}
+
+	// zones holds the timezone of registries which return dates without
+	// offset.
+	zones = map[string]string{
+		"ch":         "Europe/Zurich",
+		"cl":         "America/Santiago",
+		"cz":         "Europe/Prague",
+		"fi":         "Europe/Helsinki",
+		"hk":         "Asia/Hong_Kong",
+		"im":         "Europe/Isle_of_Man",
+		"jp":         "Asia/Tokyo",
+		"kr":         "Asia/Seoul",
+		"kz":         "Asia/Almaty",
+		"pl":         "Europe/Warsaw",
+		"rs":         "Europe/Belgrade",
+		"ru":         "Europe/Moscow",
+		"sg":         "Asia/Singapore",
+		"th":         "Asia/Bangkok",
+		"tr":         "Europe/Istanbul",
+		"tw":         "Asia/Taipei",
+		"ua":         "Europe/Kyiv",
+		"xn--j6c99c": "Asia/Hong_Kong",
+		"xn--p1ai":   "Europe/Moscow",
+	}
+)
+
+// Location returns the timezone hint for the TLD of the given domain,
+// falling back to UTC.
+func Location(domain string) *time.Location {
+	tld := strings.ToLower(domain[strings.LastIndex(domain, ".")+1:])
+	name, ok := zones[tld]
+	if !ok {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Debug().Err(err).Msgf("could not load timezone %s, using UTC", name)
+		return time.UTC
+	}
+	return location
+}
+
+// Parse parses value using the first matching layout, interpreting dates
+// without offset in the given location. It returns the date in UTC and
+// the layout that matched.
+func Parse(value string, layouts []string, location *time.Location) (time.Time, string, error) {
+	for _, layout := range layouts {
+		date, err := time.ParseInLocation(layout, value, location)
+		if err != nil {
+			continue
+		}
+		return fixAbbreviation(date).UTC(), layout, nil
+	}
+	return time.Time{}, "", fmt.Errorf("could not parse date: %q", value)
+}
+
+// fixAbbreviation applies the offset of zone abbreviations that Go could
+// not resolve, in which case it records them with zero offset. It also
+// fixes GMT+h zones, whose offset Go sets without adjusting the clock.
+func fixAbbreviation(date time.Time) time.Time {
+	name, offset := date.Zone()
+	if len(name) > 3 && strings.HasPrefix(name, "GMT") {
+		wall := date.UTC()
+		return time.Date(
+			wall.Year(), wall.Month(), wall.Day(),
+			wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(),
+			date.Location(),
+		)
+	}
+	known, ok := abbreviations[name]
+	if offset != 0 || !ok || known == 0 {
+		return date
+	}
+	return time.Date(
+		date.Year(), date.Month(), date.Day(),
+		date.Hour(), date.Minute(), date.Second(), date.Nanosecond(),
+		time.FixedZone(name, known),
+	)
+}
@@ -0,0 +1,58 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocation(t *testing.T) {
+	for domain, expect := range map[string]string{
+		"google.com":       "UTC",
+		"google.com.hk":    "Asia/Hong_Kong",
+		"GOOGLE.CL":        "America/Santiago",
+		"hkirc.xn--j6c99c": "Asia/Hong_Kong",
+		"localhost":        "UTC",
+	} {
+		t.Run(domain, func(t *testing.T) {
+			require.Equal(t, expect, Location(domain).String())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	santiago, err := time.LoadLocation("America/Santiago")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		value    string
+		layout   string
+		location *time.Location
+		expect   string
+	}{
+		{name: "utc", value: "2030-01-02 15:04:05", layout: "2006-01-02 15:04:05", location: time.UTC, expect: "2030-01-02T15:04:05Z"},
+		{name: "location", value: "2030-01-02 15:04:05", layout: "2006-01-02 15:04:05", location: santiago, expect: "2030-01-02T18:04:05Z"},
+		{name: "offset wins over location", value: "2030-01-02 15:04:05 +0100", layout: "2006-01-02 15:04:05 -0700", location: santiago, expect: "2030-01-02T14:04:05Z"},
+		{name: "known abbreviation", value: "2030-01-02 15:04:05 CLST", layout: "2006-01-02 15:04:05 MST", location: time.UTC, expect: "2030-01-02T18:04:05Z"},
+		{name: "abbreviation in location", value: "2030-01-02 15:04:05 CET", layout: "2006-01-02 15:04:05 MST", location: santiago, expect: "2030-01-02T14:04:05Z"},
+		{name: "unknown abbreviation", value: "2030-01-02 15:04:05 XYZ", layout: "2006-01-02 15:04:05 MST", location: time.UTC, expect: "2030-01-02T15:04:05Z"},
+		{name: "gmt offset", value: "2030-01-02 15:04:05 (GMT+3)", layout: "2006-01-02 15:04:05 (MST)", location: santiago, expect: "2030-01-02T12:04:05Z"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			expect, err := time.Parse(time.RFC3339, tt.expect)
+			require.NoError(t, err)
+			date, layout, err := Parse(tt.value, []string{"nope", tt.layout}, tt.location)
+			require.NoError(t, err)
+			require.Equal(t, tt.layout, layout)
+			require.Equal(t, expect, date)
+			require.Equal(t, time.UTC, date.Location())
+		})
+	}
+
+	t.Run("no layout matches", func(t *testing.T) {
+		_, _, err := Parse("soon", []string{time.RFC3339}, time.UTC)
+		require.EqualError(t, err, `could not parse date: "soon"`)
+	})
+}
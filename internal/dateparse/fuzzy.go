@@ -0,0 +1,237 @@
+package dateparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Confidence levels reported by Fuzzy.
+const (
+	// ConfidenceExact is the confidence of a date parsed by a known layout.
+	ConfidenceExact = 1.0
+	// ConfidenceMonthName is used when the month was given by name.
+	ConfidenceMonthName = 0.9
+	// ConfidenceNumeric is used when a numeric month is unambiguous.
+	ConfidenceNumeric = 0.8
+	// ConfidenceLocale is used when the day/month order came from the
+	// locale of the TLD.
+	ConfidenceLocale = 0.5
+	// shortYearPenalty is subtracted when the year has only 2 digits.
+	shortYearPenalty = 0.1
+)
+
+// nolint: gochecknoglobals
+var (
+	months = map[string]time.Month{
+		// english
+		"january": 1, "february": 2, "march": 3, "april": 4, "may": 5, "june": 6,
+		"july": 7, "august": 8, "september": 9, "october": 10, "november": 11, "december": 12,
+		"sept": 9,
+		// french
+		"janvier": 1, "février": 2, "fevrier": 2, "mars": 3, "avril": 4, "mai": 5, "juin": 6,
+		"juillet": 7, "août": 8, "aout": 8, "septembre": 9, "octobre": 10, "novembre": 11, "décembre": 12, "decembre": 12,
+		"janv": 1, "févr": 2, "fevr": 2, "juil": 7,
+		// german
+		"januar": 1, "jänner": 1, "februar": 2, "märz": 3, "maerz": 3, "juni": 6,
+		"juli": 7, "oktober": 10, "dezember": 12,
+		// spanish
+		"enero": 1, "febrero": 2, "marzo": 3, "abril": 4, "mayo": 5, "junio": 6,
+		"julio": 7, "agosto": 8, "septiembre": 9, "setiembre": 9, "octubre": 10, "noviembre": 11, "diciembre": 12,
+		// portuguese
+		"janeiro": 1, "fevereiro": 2, "março": 3, "marco": 3, "maio": 5, "junho": 6,
+		"julho": 7, "setembro": 9, "outubro": 10, "dezembro": 12,
+		// italian
+		"gennaio": 1, "febbraio": 2, "aprile": 4, "maggio": 5, "giugno": 6,
+		"luglio": 7, "settembre": 9, "ottobre": 10, "dicembre": 12,
+		// dutch
+		"januari": 1, "februari": 2, "maart": 3, "mei": 5, "augustus": 8, "mrt": 3,
+	}
+
+	// monthPrefixes maps unambiguous 3 letter prefixes of month names,
+	// e.g. "jan", "fév" or "dic".
+	monthPrefixes = buildMonthPrefixes()
+
+	// monthFirst holds the TLDs whose registries write the month first.
+	monthFirst = map[string]bool{
+		"us": true,
+		"ph": true,
+		"fm": true,
+		"pr": true,
+	}
+
+	clockRE  = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?`)
+	offsetRE = regexp.MustCompile(`\s([+-])(\d{2}):?(\d{2})\b`)
+	tokensRE = regexp.MustCompile(`\p{L}+|\d+`)
+)
+
+func buildMonthPrefixes() map[string]time.Month {
+	prefixes := map[string]time.Month{}
+	ambiguous := map[string]bool{}
+	for name, month := range months {
+		runes := []rune(name)
+		if len(runes) < 3 {
+			continue
+		}
+		prefix := string(runes[:3])
+		if m, ok := prefixes[prefix]; ok && m != month {
+			ambiguous[prefix] = true
+		}
+		prefixes[prefix] = month
+	}
+	for prefix := range ambiguous {
+		delete(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// DayFirst reports whether numeric dates of the given domain's TLD are
+// written day first, as in most of the world.
+func DayFirst(domain string) bool {
+	return !monthFirst[strings.ToLower(domain[strings.LastIndex(domain, ".")+1:])]
+}
+
+// Fuzzy parses dates that no layout matched, such as "2nd of März 2030".
+// It understands ordinal suffixes, month names in several languages, 2
+// digit years and uses dayFirst to resolve dates like "02/03/2030". It
+// returns the date in UTC along with how confident it is about it.
+func Fuzzy(value string, location *time.Location, dayFirst bool) (time.Time, float64, error) {
+	fail := fmt.Errorf("could not parse date: %q", value)
+	value = strings.ToLower(value)
+
+	var hour, minute, second int
+	if clock := clockRE.FindStringSubmatch(value); clock != nil {
+		hour, _ = strconv.Atoi(clock[1])
+		minute, _ = strconv.Atoi(clock[2])
+		if clock[3] != "" {
+			second, _ = strconv.Atoi(clock[3])
+		}
+		value = strings.Replace(value, clock[0], " ", 1)
+	}
+	if offset := offsetRE.FindStringSubmatch(value); offset != nil {
+		hours, _ := strconv.Atoi(offset[2])
+		minutes, _ := strconv.Atoi(offset[3])
+		seconds := hours*3600 + minutes*60
+		if offset[1] == "-" {
+			seconds = -seconds
+		}
+		location = time.FixedZone("", seconds)
+		value = strings.Replace(value, offset[0], " ", 1)
+	}
+
+	var month time.Month
+	var numbers []string
+	for _, token := range tokensRE.FindAllString(value, -1) {
+		if token[0] >= '0' && token[0] <= '9' {
+			numbers = append(numbers, token)
+			continue
+		}
+		if m, ok := lookupMonth(token); ok {
+			if month != 0 && month != m {
+				return time.Time{}, 0, fail
+			}
+			month = m
+		}
+	}
+
+	year, day, confidence, ok := fuzzyFields(numbers, &month, dayFirst)
+	if !ok || month < 1 || month > 12 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, 0, fail
+	}
+
+	date := time.Date(year, month, day, hour, minute, second, 0, location)
+	if date.Day() != day || date.Month() != month {
+		return time.Time{}, 0, fail
+	}
+	return date.UTC(), confidence, nil
+}
+
+func lookupMonth(token string) (time.Month, bool) {
+	if m, ok := months[token]; ok {
+		return m, true
+	}
+	// only abbreviations, so weekdays like "mardi" aren't taken for months.
+	runes := []rune(token)
+	if len(runes) < 3 || len(runes) > 4 {
+		return 0, false
+	}
+	m, ok := monthPrefixes[string(runes[:3])]
+	return m, ok
+}
+
+// fuzzyFields figures out the year, day and, if not known yet, the month
+// from the numbers found in a date.
+func fuzzyFields(numbers []string, month *time.Month, dayFirst bool) (int, int, float64, bool) {
+	yearIdx := -1
+	for i, n := range numbers {
+		if len(n) == 4 {
+			yearIdx = i
+			break
+		}
+	}
+	confidence := ConfidenceNumeric
+	if *month != 0 {
+		confidence = ConfidenceMonthName
+	}
+	if yearIdx == -1 {
+		// 2 digit years are usually last, unless they can't be a day.
+		yearIdx = len(numbers) - 1
+		for i, n := range numbers {
+			if v, _ := strconv.Atoi(n); len(n) == 2 && v > 31 {
+				yearIdx = i
+			}
+		}
+		if yearIdx < 0 || len(numbers[yearIdx]) != 2 {
+			return 0, 0, 0, false
+		}
+		confidence -= shortYearPenalty
+	}
+
+	year, _ := strconv.Atoi(numbers[yearIdx])
+	if year < 100 {
+		year += 2000
+	}
+	var rest []int
+	for i, n := range numbers {
+		if i == yearIdx || len(n) > 2 {
+			continue
+		}
+		v, _ := strconv.Atoi(n)
+		rest = append(rest, v)
+	}
+
+	if *month != 0 {
+		if len(rest) != 1 {
+			return 0, 0, 0, false
+		}
+		return year, rest[0], confidence, true
+	}
+
+	if len(rest) != 2 {
+		return 0, 0, 0, false
+	}
+	first, second := rest[0], rest[1]
+	switch {
+	case yearIdx == 0:
+		// ISO-like order: year, month, day.
+		*month = time.Month(first)
+		return year, second, confidence, true
+	case first > 12:
+		*month = time.Month(second)
+		return year, first, confidence, true
+	case second > 12:
+		*month = time.Month(first)
+		return year, second, confidence, true
+	case first == second:
+		*month = time.Month(first)
+		return year, first, confidence, true
+	case dayFirst:
+		*month = time.Month(second)
+		return year, first, confidence - (ConfidenceNumeric - ConfidenceLocale), true
+	default:
+		*month = time.Month(first)
+		return year, second, confidence - (ConfidenceNumeric - ConfidenceLocale), true
+	}
+}
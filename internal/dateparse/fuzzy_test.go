@@ -0,0 +1,76 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDayFirst(t *testing.T) {
+	require.True(t, DayFirst("google.fr"))
+	require.True(t, DayFirst("google.com"))
+	require.False(t, DayFirst("google.us"))
+	require.False(t, DayFirst("GOOGLE.PH"))
+}
+
+func TestFuzzy(t *testing.T) {
+	for _, tt := range []struct {
+		value      string
+		monthFirst bool
+		expect     string
+		confidence float64
+	}{
+		{value: "2nd of January 2030", expect: "2030-01-02T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "January 2nd, 2030", expect: "2030-01-02T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "Wednesday, the 23rd of Sept. 2030", expect: "2030-09-23T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "1er février 2030", expect: "2030-02-01T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "mardi 5 mars 2030", expect: "2030-03-05T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "2. März 2030 15:04", expect: "2030-03-02T15:04:00Z", confidence: ConfidenceMonthName},
+		{value: "12 de diciembre de 2030", expect: "2030-12-12T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "7 de março de 2030", expect: "2030-03-07T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "15 ottobre 2030", expect: "2030-10-15T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "3 mei 2030", expect: "2030-05-03T00:00:00Z", confidence: ConfidenceMonthName},
+		{value: "5-Dez-30", expect: "2030-12-05T00:00:00Z", confidence: ConfidenceMonthName - shortYearPenalty},
+		{value: "2030/1/2 3:04:05", expect: "2030-01-02T03:04:05Z", confidence: ConfidenceNumeric},
+		{value: "25/12/2030", expect: "2030-12-25T00:00:00Z", confidence: ConfidenceNumeric},
+		{value: "12/25/2030", expect: "2030-12-25T00:00:00Z", confidence: ConfidenceNumeric},
+		{value: "02/03/2030", expect: "2030-03-02T00:00:00Z", confidence: ConfidenceLocale},
+		{value: "02/03/2030", monthFirst: true, expect: "2030-02-03T00:00:00Z", confidence: ConfidenceLocale},
+		{value: "02/03/30", expect: "2030-03-02T00:00:00Z", confidence: ConfidenceLocale - shortYearPenalty},
+		{value: "05.05.2030 10:00:00 +0200", expect: "2030-05-05T08:00:00Z", confidence: ConfidenceNumeric},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			expect, err := time.Parse(time.RFC3339, tt.expect)
+			require.NoError(t, err)
+			date, confidence, err := Fuzzy(tt.value, time.UTC, !tt.monthFirst)
+			require.NoError(t, err)
+			require.Equal(t, expect, date)
+			require.InDelta(t, tt.confidence, confidence, 0.0001)
+		})
+	}
+
+	for _, value := range []string{
+		"soon",
+		"",
+		"January March 2030",
+		"31/02/2030",
+		"13/13/2030",
+		"2030",
+		"02 03 04 2030",
+		"25:00 01/02/2030",
+	} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			_, _, err := Fuzzy(value, time.UTC, true)
+			require.EqualError(t, err, "could not parse date: \""+value+"\"")
+		})
+	}
+
+	t.Run("location", func(t *testing.T) {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+		date, _, err := Fuzzy("2 January 2030", tokyo, true)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2030, 1, 1, 15, 0, 0, 0, time.UTC), date)
+	})
+}
@@ -0,0 +1,208 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      string      `json:"query"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Type        string      `json:"type"`
+	Datasource  datasource  `json:"datasource"`
+	GridPos     gridPos     `json:"gridPos"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+	Targets     []target    `json:"targets"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type fieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit,omitempty"`
+	} `json:"defaults"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+// dashboardPanels are the panels of the dashboard, each one with its
+// queries, all of them over metrics exposed by the exporter.
+//
+// nolint: gochecknoglobals
+var dashboardPanels = []struct {
+	title  string
+	typ    string
+	unit   string
+	table  bool
+	exprs  []string
+	legend string
+}{
+	{
+		title: "Days until expiry",
+		typ:   "table",
+		unit:  "d",
+		table: true,
+		exprs: []string{
+			`(domain_expiry_timestamp_seconds{source="effective",domain=~"$domain"} - time()) / 86400`,
+			`domain_expiry_warning_threshold_days{domain=~"$domain"}`,
+			`domain_expiry_critical_threshold_days{domain=~"$domain"}`,
+		},
+	},
+	{
+		title:  "Probe success",
+		typ:    "timeseries",
+		exprs:  []string{`domain_probe_success{domain=~"$domain"}`},
+		legend: "{{domain}}",
+	},
+	{
+		title:  "Probe failures by reason",
+		typ:    "timeseries",
+		exprs:  []string{`sum by (reason) (domain_probe_failure{domain=~"$domain"})`},
+		legend: "{{reason}}",
+	},
+	{
+		title:  "Probe duration",
+		typ:    "timeseries",
+		unit:   "s",
+		exprs:  []string{`domain_probe_duration_seconds{domain=~"$domain"}`},
+		legend: "{{domain}}",
+	},
+	{
+		title:  "Backend requests",
+		typ:    "timeseries",
+		unit:   "reqps",
+		exprs:  []string{`sum by (backend, result) (rate(domain_backend_requests_total[5m]))`},
+		legend: "{{backend}} {{result}}",
+	},
+	{
+		title:  "Backend request duration (p95)",
+		typ:    "timeseries",
+		unit:   "s",
+		exprs:  []string{`histogram_quantile(0.95, sum by (backend, le) (rate(domain_backend_request_duration_seconds_bucket[5m])))`},
+		legend: "{{backend}}",
+	},
+	{
+		title:  "Cache hit ratio",
+		typ:    "timeseries",
+		unit:   "percentunit",
+		exprs:  []string{`sum(rate(domain_cache_hits_total[5m])) / (sum(rate(domain_cache_hits_total[5m])) + sum(rate(domain_cache_misses_total[5m])))`},
+		legend: "hits",
+	},
+	{
+		title:  "Renewals",
+		typ:    "timeseries",
+		exprs:  []string{`increase(domain_renewals_total{domain=~"$domain"}[1d])`},
+		legend: "{{domain}}",
+	},
+	{
+		title:  "Cache entries",
+		typ:    "timeseries",
+		exprs:  []string{`domain_cache_entries`},
+		legend: "entries",
+	},
+}
+
+// Dashboard returns a Grafana dashboard for the metrics of the exporter.
+func Dashboard() ([]byte, error) {
+	ds := datasource{Type: "prometheus", UID: "${datasource}"}
+	result := dashboard{
+		UID:           "domain-exporter",
+		Title:         "Domains",
+		Tags:          []string{"domain_exporter"},
+		SchemaVersion: 39,
+		Refresh:       "5m",
+		Time:          timeRange{From: "now-7d", To: "now"},
+		Templating: templating{List: []variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{
+				Name:       "domain",
+				Label:      "Domain",
+				Type:       "query",
+				Query:      "label_values(domain_probe_success, domain)",
+				Datasource: &ds,
+				Multi:      true,
+				IncludeAll: true,
+				Refresh:    2,
+			},
+		}},
+	}
+	x, y := 0, 0
+	for i, spec := range dashboardPanels {
+		width := 12
+		if spec.table {
+			width = 24
+		}
+		if x+width > 24 {
+			x, y = 0, y+8
+		}
+		p := panel{
+			ID:         i + 1,
+			Title:      spec.title,
+			Type:       spec.typ,
+			Datasource: ds,
+			GridPos:    gridPos{H: 8, W: width, X: x, Y: y},
+		}
+		x += width
+		p.FieldConfig.Defaults.Unit = spec.unit
+		for j, expr := range spec.exprs {
+			t := target{RefID: string(rune('A' + j)), Expr: expr, LegendFormat: spec.legend}
+			if spec.table {
+				t.Instant, t.Format = true, "table"
+			}
+			p.Targets = append(p.Targets, t)
+		}
+		result.Panels = append(result.Panels, p)
+	}
+	bts, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal dashboard: %w", err)
+	}
+	return append(bts, '\n'), nil
+}
@@ -0,0 +1,33 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	bts, err := Dashboard()
+	require.NoError(t, err)
+
+	var got dashboard
+	require.NoError(t, json.Unmarshal(bts, &got))
+	require.Equal(t, "domain-exporter", got.UID)
+	require.Len(t, got.Panels, len(dashboardPanels))
+
+	var exprs []string
+	for _, p := range got.Panels {
+		require.NotEmpty(t, p.Targets, p.Title)
+		require.LessOrEqual(t, p.GridPos.X+p.GridPos.W, 24, p.Title)
+		for _, t := range p.Targets {
+			exprs = append(exprs, t.Expr)
+		}
+	}
+	requireExposed(t, exprs...)
+	require.Equal(t, []target{
+		{RefID: "A", Expr: `(domain_expiry_timestamp_seconds{source="effective",domain=~"$domain"} - time()) / 86400`, Instant: true, Format: "table"},
+		{RefID: "B", Expr: `domain_expiry_warning_threshold_days{domain=~"$domain"}`, Instant: true, Format: "table"},
+		{RefID: "C", Expr: `domain_expiry_critical_threshold_days{domain=~"$domain"}`, Instant: true, Format: "table"},
+	}, got.Panels[0].Targets)
+}
@@ -0,0 +1,181 @@
+package generate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFormat is the format of the generated alerting rules.
+type RulesFormat string
+
+// Available rules formats.
+const (
+	// FormatRules is a Prometheus rules file.
+	FormatRules RulesFormat = "rules"
+	// FormatPrometheusRule is a PrometheusRule of the Prometheus Operator.
+	FormatPrometheusRule RulesFormat = "prometheus-rule"
+)
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name   string            `yaml:"name"`
+		Labels map[string]string `yaml:"labels,omitempty"`
+	} `yaml:"metadata"`
+	Spec ruleGroups `yaml:"spec"`
+}
+
+// Rules returns the alerting rules for the configured domains, in the given
+// format. The domains of each configured group get their own rules, with a
+// group label, and every rule gets the given labels.
+//
+// The rules compare the expiry with the threshold metrics, so they follow
+// the thresholds of each domain.
+func Rules(cfg safeconfig.SafeConfig, format RulesFormat, labels map[string]string) ([]byte, error) {
+	var rules ruleGroups
+	var grouped []string
+	names := make([]string, 0, len(cfg.Groups))
+	for name := range cfg.Groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var domains []string
+		for _, domain := range cfg.Groups[name].Domains {
+			domains = append(domains, domain.Name)
+		}
+		if len(domains) == 0 {
+			continue
+		}
+		grouped = append(grouped, domains...)
+		rules.Groups = append(rules.Groups, ruleGroup{
+			Name:  "domain_" + name,
+			Rules: alerts(fmt.Sprintf("domain=~%s", domainsRegexp(domains)), withLabel(labels, "group", name)),
+		})
+	}
+	selector := ""
+	if len(grouped) != 0 {
+		selector = fmt.Sprintf("domain!~%s", domainsRegexp(grouped))
+	}
+	rules.Groups = append([]ruleGroup{{Name: "domain", Rules: alerts(selector, labels)}}, rules.Groups...)
+
+	switch format {
+	case FormatRules:
+		return marshal(rules)
+	case FormatPrometheusRule:
+		resource := prometheusRule{
+			APIVersion: "monitoring.coreos.com/v1",
+			Kind:       "PrometheusRule",
+			Spec:       rules,
+		}
+		resource.Metadata.Name = "domain-exporter"
+		resource.Metadata.Labels = labels
+		return marshal(resource)
+	default:
+		return nil, fmt.Errorf("invalid rules format %q", format)
+	}
+}
+
+// alerts returns the alerts for the domains matching the selector.
+func alerts(selector string, labels map[string]string) []rule {
+	expiry := metric("domain_expiry_timestamp_seconds", `source="effective"`, selector)
+	left := fmt.Sprintf("max_over_time(%s[1h]) - time()", expiry)
+	warning := fmt.Sprintf("%s <= ignoring(source) 86400 * %s", left, metric("domain_expiry_warning_threshold_days", selector))
+	critical := fmt.Sprintf("%s <= ignoring(source) 86400 * %s", left, metric("domain_expiry_critical_threshold_days", selector))
+	return []rule{
+		{
+			Alert:  "DomainExpiring",
+			Expr:   fmt.Sprintf("(\n  %s\n)\nunless\n(\n  %s\n)", warning, critical),
+			For:    "1h",
+			Labels: withLabel(labels, "severity", "warning"),
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.domain }}: domain is expiring",
+				"description": "Domain {{ $labels.domain }} will expire in {{ $value | humanizeDuration }}",
+			},
+		},
+		{
+			Alert:  "DomainExpiringSoon",
+			Expr:   critical,
+			For:    "1h",
+			Labels: withLabel(labels, "severity", "critical"),
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.domain }}: domain is expiring soon",
+				"description": "Domain {{ $labels.domain }} will expire in {{ $value | humanizeDuration }}",
+			},
+		},
+		{
+			Alert:  "DomainProbeFailure",
+			Expr:   metric("domain_probe_success", selector) + " == 0",
+			For:    "1h",
+			Labels: withLabel(labels, "severity", "warning"),
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.domain }}: cannot probe",
+				"description": "Domain {{ $labels.domain }} cannot be probed",
+			},
+		},
+	}
+}
+
+// metric returns the metric with the given matchers, ignoring empty ones.
+func metric(name string, matchers ...string) string {
+	matchers = slices.DeleteFunc(matchers, func(matcher string) bool {
+		return matcher == ""
+	})
+	if len(matchers) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// domainsRegexp returns a quoted regexp matching exactly the domains.
+func domainsRegexp(domains []string) string {
+	quoted := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		quoted = append(quoted, regexp.QuoteMeta(domain))
+	}
+	return strconv.Quote(strings.Join(quoted, "|"))
+}
+
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	result := map[string]string{key: value}
+	for k, v := range labels {
+		if _, ok := result[k]; !ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func marshal(v any) ([]byte, error) {
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal rules: %w", err)
+	}
+	return []byte(buf.String()), nil
+}
@@ -0,0 +1,123 @@
+package generate
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/collector"
+	"github.com/caarlos0/domain_exporter/internal/history"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// nolint: gochecknoglobals
+var (
+	fqNameRE = regexp.MustCompile(`fqName: "([^"]+)"`)
+	metricRE = regexp.MustCompile(`\bdomain_[a-z_]+`)
+)
+
+// requireExposed checks the metrics used in the expressions are exposed by
+// the exporter.
+func requireExposed(t *testing.T, exprs ...string) {
+	t.Helper()
+	exposed := map[string]bool{}
+	hist, err := history.New("")
+	require.NoError(t, err)
+	ch := make(chan *prometheus.Desc, 100)
+	for _, c := range []prometheus.Collector{
+		hist,
+		collector.NewDomainCollector(context.Background(), nil, time.Second, false),
+		collector.NewSnapshotCollector(nil, false),
+		client.NewMetrics(),
+		client.NewCachedClient(nil, cache.New(time.Minute, time.Minute), ""),
+	} {
+		c.Describe(ch)
+	}
+	close(ch)
+	for desc := range ch {
+		exposed[fqNameRE.FindStringSubmatch(desc.String())[1]] = true
+	}
+
+	for _, expr := range exprs {
+		for _, name := range metricRE.FindAllString(expr, -1) {
+			base := strings.TrimSuffix(name, "_bucket")
+			require.True(t, exposed[name] || exposed[base], "%s is not exposed", name)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	cfg := safeconfig.SafeConfig{
+		Domains: []safeconfig.Domain{{Name: "foo.com"}},
+		Groups: map[string]safeconfig.Group{
+			"payments": {Domains: []safeconfig.Domain{{Name: "pay.com"}, {Name: "checkout.com"}}},
+			"blogs":    {Domains: []safeconfig.Domain{{Name: "blog.com"}}},
+		},
+	}
+	bts, err := Rules(cfg, FormatRules, map[string]string{"team": "ops"})
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(bts, &rules))
+	require.Len(t, rules.Groups, 3)
+	require.Equal(t, "domain", rules.Groups[0].Name)
+	require.Equal(t, "domain_blogs", rules.Groups[1].Name)
+	require.Equal(t, "domain_payments", rules.Groups[2].Name)
+
+	var exprs []string
+	for _, group := range rules.Groups {
+		require.Len(t, group.Rules, 3)
+		for _, rule := range group.Rules {
+			require.Equal(t, "ops", rule.Labels["team"])
+			require.NotEmpty(t, rule.Labels["severity"])
+			exprs = append(exprs, rule.Expr)
+		}
+	}
+	requireExposed(t, exprs...)
+
+	expiring := rules.Groups[0].Rules[0]
+	require.Equal(t, "DomainExpiring", expiring.Alert)
+	require.Contains(t, expiring.Expr, `domain!~"blog\\.com|pay\\.com|checkout\\.com"`)
+	require.Contains(t, expiring.Expr, "domain_expiry_warning_threshold_days")
+	require.Empty(t, expiring.Labels["group"])
+
+	payments := rules.Groups[2].Rules[1]
+	require.Equal(t, "DomainExpiringSoon", payments.Alert)
+	require.Equal(t, "critical", payments.Labels["severity"])
+	require.Equal(t, "payments", payments.Labels["group"])
+	require.Contains(t, payments.Expr, `domain=~"pay\\.com|checkout\\.com"`)
+	require.Contains(t, payments.Expr, "domain_expiry_critical_threshold_days")
+}
+
+func TestRulesNoGroups(t *testing.T) {
+	bts, err := Rules(safeconfig.SafeConfig{}, FormatRules, nil)
+	require.NoError(t, err)
+
+	var rules ruleGroups
+	require.NoError(t, yaml.Unmarshal(bts, &rules))
+	require.Len(t, rules.Groups, 1)
+	require.Equal(t, "domain_probe_success == 0", rules.Groups[0].Rules[2].Expr)
+}
+
+func TestPrometheusRule(t *testing.T) {
+	bts, err := Rules(safeconfig.SafeConfig{}, FormatPrometheusRule, map[string]string{"release": "prometheus"})
+	require.NoError(t, err)
+
+	var resource prometheusRule
+	require.NoError(t, yaml.Unmarshal(bts, &resource))
+	require.Equal(t, "monitoring.coreos.com/v1", resource.APIVersion)
+	require.Equal(t, "PrometheusRule", resource.Kind)
+	require.Equal(t, "domain-exporter", resource.Metadata.Name)
+	require.Equal(t, map[string]string{"release": "prometheus"}, resource.Metadata.Labels)
+	require.Len(t, resource.Spec.Groups, 1)
+
+	_, err = Rules(safeconfig.SafeConfig{}, "foo", nil)
+	require.EqualError(t, err, `invalid rules format "foo"`)
+}
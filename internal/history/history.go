@@ -0,0 +1,246 @@
+// Package history keeps the expiry dates seen for each domain, detecting
+// renewals.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// tolerance is how much an expiry may move without being a new one, as
+// backends may report it with different precision or timezones.
+const tolerance = 24 * time.Hour
+
+// maxObservations is how many expiry dates are kept for each domain.
+const maxObservations = 100
+
+// Observation is an expiry date and when it was seen.
+type Observation struct {
+	Expiry    time.Time `json:"expiry"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Renewal is a jump of the expiry date past the latest one seen before.
+type Renewal struct {
+	At   time.Time `json:"at"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Days is how many days the expiry moved forward.
+	Days int `json:"days"`
+}
+
+// Record is the history of a domain.
+type Record struct {
+	Observations []Observation `json:"observations"`
+	Renewals     []Renewal     `json:"renewals"`
+}
+
+// History is the history of the domains, persisted to a file, if set.
+type History struct {
+	path string
+
+	mutex   sync.Mutex
+	records map[string]Record
+
+	lastRenewal *prometheus.Desc
+	renewals    *prometheus.Desc
+}
+
+// New returns the history persisted in the given file, if any.
+func New(path string) (*History, error) {
+	records := map[string]Record{}
+	if path != "" {
+		bts, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		default:
+			if err := json.Unmarshal(bts, &records); err != nil {
+				return nil, fmt.Errorf("failed to parse history: %w", err)
+			}
+		}
+	}
+	return &History{
+		path:    path,
+		records: records,
+		lastRenewal: prometheus.NewDesc(
+			"domain_last_renewal_timestamp_seconds",
+			"when the last renewal of the domain was seen, in unix seconds",
+			[]string{"domain"},
+			nil,
+		),
+		renewals: prometheus.NewDesc(
+			"domain_renewals_total",
+			"total of renewals of the domain seen",
+			[]string{"domain"},
+			nil,
+		),
+	}, nil
+}
+
+// Observe records the expiry of the domain seen at the given time, and
+// returns whether it was a renewal. Only expiry dates later than all the
+// ones seen before are renewals, so backends or sources that disagree don't
+// count as renewals each time they take turns.
+func (h *History) Observe(domain string, expiry, at time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	record := h.records[domain]
+	if n := len(record.Observations); n != 0 {
+		last := &record.Observations[n-1]
+		if expiry.Sub(last.Expiry).Abs() <= tolerance {
+			last.LastSeen = at
+			return false
+		}
+	}
+
+	renewed := false
+	if from := record.latest(); !from.IsZero() && expiry.Sub(from) > tolerance {
+		record.Renewals = append(record.Renewals, Renewal{
+			At:   at,
+			From: from,
+			To:   expiry,
+			Days: int(math.Round(expiry.Sub(from).Hours() / 24)),
+		})
+		renewed = true
+		log.Info().Msgf("%s was renewed from %s to %s", domain, from, expiry)
+	}
+	record.Observations = append(record.Observations, Observation{Expiry: expiry, FirstSeen: at, LastSeen: at})
+	if len(record.Observations) > maxObservations {
+		record.Observations = record.Observations[len(record.Observations)-maxObservations:]
+	}
+
+	h.records[domain] = record
+	if err := h.save(); err != nil {
+		log.Error().Err(err).Msg("failed to save history")
+	}
+	return renewed
+}
+
+// latest returns the latest expiry date seen, if any.
+func (r Record) latest() time.Time {
+	var latest time.Time
+	for _, observation := range r.Observations {
+		if observation.Expiry.After(latest) {
+			latest = observation.Expiry
+		}
+	}
+	// old observations are dropped, but renewals are kept.
+	for _, renewal := range r.Renewals {
+		if renewal.To.After(latest) {
+			latest = renewal.To
+		}
+	}
+	return latest
+}
+
+// Record returns the history of the domain.
+func (h *History) Record(domain string) (Record, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	record, ok := h.records[domain]
+	return record, ok
+}
+
+// save writes the history to its file atomically, if one is set. Only new
+// expiry dates are saved right away, so the last seen times may be older.
+func (h *History) save() error {
+	if h.path == "" {
+		return nil
+	}
+	bts, err := json.MarshalIndent(h.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), filepath.Base(h.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(bts); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Describe all metrics
+func (h *History) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.lastRenewal
+	ch <- h.renewals
+}
+
+// Collect all metrics
+func (h *History) Collect(ch chan<- prometheus.Metric) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	domains := make([]string, 0, len(h.records))
+	for domain := range h.records {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		renewals := h.records[domain].Renewals
+		ch <- prometheus.MustNewConstMetric(
+			h.renewals,
+			prometheus.CounterValue,
+			float64(len(renewals)),
+			domain,
+		)
+		if len(renewals) == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			h.lastRenewal,
+			prometheus.GaugeValue,
+			float64(renewals[len(renewals)-1].At.Unix()),
+			domain,
+		)
+	}
+}
+
+// NewClient returns a client that records the expiry dates the given client
+// looks up in the history. Dates not parsed exactly, such as the ones
+// assumed from an active status, move on every lookup, so they are ignored.
+func NewClient(client client.Client, history *History) client.Client {
+	return historyClient{
+		client:  client,
+		history: history,
+	}
+}
+
+type historyClient struct {
+	client  client.Client
+	history *History
+}
+
+func (c historyClient) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
+	result, err := c.client.Lookup(ctx, domain, host)
+	if err == nil && !result.Expiry.IsZero() && result.Confidence >= dateparse.ConfidenceExact {
+		c.history.Observe(domain, result.Expiry, time.Now())
+	}
+	return result, err
+}
@@ -0,0 +1,142 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	history, err := New("")
+	require.NoError(t, err)
+
+	day := func(n int) time.Time { return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n) }
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.False(t, history.Observe("foo.com", day(0), seen))
+	require.False(t, history.Observe("foo.com", day(0).Add(3*time.Hour), seen.Add(time.Hour)))
+	require.True(t, history.Observe("foo.com", day(365), seen.Add(2*time.Hour)))
+	require.False(t, history.Observe("foo.com", day(300), seen.Add(3*time.Hour)))
+
+	record, ok := history.Record("foo.com")
+	require.True(t, ok)
+	require.Equal(t, []Observation{
+		{Expiry: day(0), FirstSeen: seen, LastSeen: seen.Add(time.Hour)},
+		{Expiry: day(365), FirstSeen: seen.Add(2 * time.Hour), LastSeen: seen.Add(2 * time.Hour)},
+		{Expiry: day(300), FirstSeen: seen.Add(3 * time.Hour), LastSeen: seen.Add(3 * time.Hour)},
+	}, record.Observations)
+	require.Equal(t, []Renewal{
+		{At: seen.Add(2 * time.Hour), From: day(0), To: day(365), Days: 365},
+	}, record.Renewals)
+
+	_, ok = history.Record("bar.com")
+	require.False(t, ok)
+}
+
+func TestObserveFlipFlop(t *testing.T) {
+	history, err := New("")
+	require.NoError(t, err)
+
+	// e.g. the registry and the registrar dates, as different backends win.
+	registry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	registrar := registry.AddDate(0, 0, 30)
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var renewals int
+	for i, expiry := range []time.Time{registry, registrar, registry, registrar, registry, registrar} {
+		if history.Observe("foo.com", expiry, seen.Add(time.Duration(i)*time.Hour)) {
+			renewals++
+		}
+	}
+	require.Equal(t, 1, renewals)
+
+	require.True(t, history.Observe("foo.com", registrar.AddDate(1, 0, 0), seen.Add(24*time.Hour)))
+	record, _ := history.Record("foo.com")
+	require.Len(t, record.Renewals, 2)
+	require.Equal(t, registrar, record.Renewals[1].From)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	history, err := New(path)
+	require.NoError(t, err)
+
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	history.Observe("foo.com", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), seen)
+	history.Observe("foo.com", time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC), seen.Add(time.Hour))
+
+	restarted, err := New(path)
+	require.NoError(t, err)
+	record, ok := restarted.Record("foo.com")
+	require.True(t, ok)
+	require.Len(t, record.Observations, 2)
+	require.Len(t, record.Renewals, 1)
+	require.True(t, seen.Add(time.Hour).Equal(record.Renewals[0].At))
+
+	_, err = New(filepath.Join(t.TempDir(), "missing", "history.json"))
+	require.NoError(t, err)
+}
+
+func TestMetrics(t *testing.T) {
+	history, err := New("")
+	require.NoError(t, err)
+
+	renewed := time.Unix(1800000000, 0)
+	history.Observe("foo.com", time.Unix(1900000000, 0), renewed.Add(-time.Hour))
+	history.Observe("foo.com", time.Unix(1930000000, 0), renewed)
+	history.Observe("bar.com", time.Unix(1900000000, 0), renewed)
+
+	require.NoError(t, testutil.CollectAndCompare(history, strings.NewReader(`
+# HELP domain_last_renewal_timestamp_seconds when the last renewal of the domain was seen, in unix seconds
+# TYPE domain_last_renewal_timestamp_seconds gauge
+domain_last_renewal_timestamp_seconds{domain="foo.com"} 1.8e+09
+# HELP domain_renewals_total total of renewals of the domain seen
+# TYPE domain_renewals_total counter
+domain_renewals_total{domain="bar.com"} 0
+domain_renewals_total{domain="foo.com"} 1
+`)))
+}
+
+type fakeClient struct {
+	result client.Result
+	err    error
+}
+
+func (f fakeClient) Lookup(_ context.Context, _ string, _ string) (client.Result, error) {
+	return f.result, f.err
+}
+
+func TestClient(t *testing.T) {
+	history, err := New("")
+	require.NoError(t, err)
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = NewClient(fakeClient{result: client.Result{Expiry: expiry, Confidence: dateparse.ConfidenceExact}}, history).Lookup(context.Background(), "foo.com", "")
+	require.NoError(t, err)
+	_, err = NewClient(fakeClient{err: errors.New("fail")}, history).Lookup(context.Background(), "bar.com", "")
+	require.Error(t, err)
+
+	record, ok := history.Record("foo.com")
+	require.True(t, ok)
+	require.Equal(t, expiry, record.Observations[0].Expiry)
+	_, ok = history.Record("bar.com")
+	require.False(t, ok)
+
+	t.Run("inexact dates", func(t *testing.T) {
+		// e.g. assumed from an active status, moving forward on each lookup.
+		for i := range 120 {
+			result := client.Result{Expiry: expiry.Add(time.Duration(i) * 2 * time.Hour), Confidence: 0.5}
+			_, err := NewClient(fakeClient{result: result}, history).Lookup(context.Background(), "drift.kz", "")
+			require.NoError(t, err)
+		}
+		_, ok := history.Record("drift.kz")
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,232 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultInterval is how often to check, unless the config sets it.
+const defaultInterval = time.Hour
+
+// Level is how close to expiring a domain is.
+type Level string
+
+// Available levels.
+const (
+	LevelOK       Level = "ok"
+	LevelWarning  Level = "warning"
+	LevelCritical Level = "critical"
+)
+
+// Notification tells that a domain changed level.
+type Notification struct {
+	Domain   string    `json:"domain"`
+	Level    Level     `json:"level"`
+	Previous Level     `json:"previous"`
+	Expiry   time.Time `json:"expiry"`
+	DaysLeft int       `json:"days_left"`
+	Message  string    `json:"message"`
+}
+
+// Statuser returns the status of a domain, as last seen by the refresher.
+type Statuser interface {
+	Status(name string) (refresher.Status, bool)
+	// Refreshed is closed once the first refresh is done.
+	Refreshed() <-chan struct{}
+}
+
+// Notifier checks the domains against their thresholds, notifying each
+// change of level once.
+type Notifier struct {
+	cfg      safeconfig.Notifications
+	statuser Statuser
+	domains  []safeconfig.Domain
+	// senders are keyed by where they send to, see senderKey.
+	senders map[string]Sender
+
+	mutex sync.Mutex
+	// state is the last level notified for each domain, by each sender.
+	state map[string]map[string]Level
+}
+
+// New returns a notifier for the given domains, sending to the senders in
+// the config, with the state of the previous runs, if any.
+func New(cfg safeconfig.Notifications, statuser Statuser, domains ...safeconfig.Domain) (*Notifier, error) {
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	senders := newSenders(cfg)
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		cfg:      cfg,
+		statuser: statuser,
+		domains:  domains,
+		senders:  senders,
+		state:    state,
+	}, nil
+}
+
+// Run checks the domains once the first refresh is done and then on every
+// interval until ctx is done.
+func (n *Notifier) Run(ctx context.Context) {
+	log.Info().Msg("run notifier")
+	select {
+	case <-n.statuser.Refreshed():
+		n.Check(ctx)
+	case <-ctx.Done():
+		log.Info().Msg("notifier is finished")
+		return
+	}
+
+	ticker := time.NewTicker(n.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.Check(ctx)
+		case <-ctx.Done():
+			log.Info().Msg("notifier is finished")
+			return
+		}
+	}
+}
+
+// Check notifies the domains whose level changed since they were last
+// notified. Domains never looked up successfully are skipped. Each sender
+// keeps its own state, so a failed notification is retried on the next
+// check only by the senders that failed.
+func (n *Notifier) Check(ctx context.Context) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	changed := false
+	for _, domain := range n.domains {
+		status, ok := n.statuser.Status(domain.Name)
+		if !ok || status.LastSuccess.IsZero() {
+			continue
+		}
+		daysLeft := int(math.Floor(time.Until(status.Result.Expiry).Hours() / 24))
+		level := levelOf(domain, daysLeft)
+		for _, key := range slices.Sorted(maps.Keys(n.senders)) {
+			previous, ok := n.state[domain.Name][key]
+			if !ok {
+				previous = LevelOK
+			}
+			if level == previous {
+				continue
+			}
+
+			notification := Notification{
+				Domain:   domain.Name,
+				Level:    level,
+				Previous: previous,
+				Expiry:   status.Result.Expiry,
+				DaysLeft: daysLeft,
+				Message:  message(domain.Name, level, daysLeft, status.Result.Expiry),
+			}
+			if err := n.senders[key].Send(ctx, notification); err != nil {
+				log.Error().Err(err).Msgf("failed to notify %s", domain.Name)
+				continue
+			}
+			if n.state[domain.Name] == nil {
+				n.state[domain.Name] = map[string]Level{}
+			}
+			n.state[domain.Name][key] = level
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := saveState(n.cfg.StateFile, n.state); err != nil {
+		log.Error().Err(err).Msg("failed to save notifier state")
+	}
+}
+
+// levelOf returns the level of the domain given the days left.
+func levelOf(domain safeconfig.Domain, daysLeft int) Level {
+	warning, critical := domain.Thresholds()
+	switch {
+	case daysLeft <= critical:
+		return LevelCritical
+	case daysLeft <= warning:
+		return LevelWarning
+	default:
+		return LevelOK
+	}
+}
+
+func message(domain string, level Level, daysLeft int, expiry time.Time) string {
+	date := expiry.UTC().Format(time.DateOnly)
+	switch {
+	case level == LevelOK:
+		return fmt.Sprintf("%s is no longer expiring soon, it expires on %s", domain, date)
+	case daysLeft < 0:
+		return fmt.Sprintf("%s expired on %s", domain, date)
+	default:
+		return fmt.Sprintf("%s expires in %d days, on %s", domain, daysLeft, date)
+	}
+}
+
+// loadState reads the state file, if any.
+func loadState(path string) (map[string]map[string]Level, error) {
+	state := map[string]map[string]Level{}
+	if path == "" {
+		return state, nil
+	}
+	bts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifier state: %w", err)
+	}
+	if err := json.Unmarshal(bts, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse notifier state: %w", err)
+	}
+	return state, nil
+}
+
+// saveState writes the state file atomically, if one is set.
+func saveState(path string, state map[string]map[string]Level) error {
+	if path == "" {
+		return nil
+	}
+	bts, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal notifier state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write notifier state: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(bts); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write notifier state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write notifier state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write notifier state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/refresher"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStatuser map[string]refresher.Status
+
+func (f fakeStatuser) Status(name string) (refresher.Status, bool) {
+	status, ok := f[name]
+	return status, ok
+}
+
+func (f fakeStatuser) Refreshed() <-chan struct{} {
+	refreshed := make(chan struct{})
+	close(refreshed)
+	return refreshed
+}
+
+func (f fakeStatuser) set(name string, days int) {
+	f[name] = refresher.Status{
+		Domain:      safeconfig.Domain{Name: name},
+		Result:      client.Result{Expiry: time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour)},
+		LastSuccess: time.Now(),
+	}
+}
+
+// recorder is a webhook that records the notifications it receives.
+type recorder struct {
+	mutex         sync.Mutex
+	notifications []Notification
+	status        int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+		return
+	}
+	var notification Notification
+	if err := json.NewDecoder(req.Body).Decode(&notification); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.notifications = append(r.notifications, notification)
+}
+
+func (r *recorder) levels() []Level {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var levels []Level
+	for _, notification := range r.notifications {
+		levels = append(levels, notification.Level)
+	}
+	return levels
+}
+
+func TestCheck(t *testing.T) {
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	statuser := fakeStatuser{}
+	statuser.set("foo.com", 20)
+	cfg := safeconfig.Notifications{Webhooks: []string{srv.URL}}
+	notifier, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"}, safeconfig.Domain{Name: "pending.com"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	notifier.Check(ctx)
+	require.Equal(t, []Level{LevelWarning}, hook.levels())
+	require.Equal(t, "foo.com", hook.notifications[0].Domain)
+	require.Equal(t, LevelOK, hook.notifications[0].Previous)
+	require.Equal(t, 20, hook.notifications[0].DaysLeft)
+	require.Contains(t, hook.notifications[0].Message, "foo.com expires in 20 days")
+
+	t.Run("deduplicates", func(t *testing.T) {
+		notifier.Check(ctx)
+		require.Equal(t, []Level{LevelWarning}, hook.levels())
+	})
+
+	t.Run("escalates and resolves", func(t *testing.T) {
+		statuser.set("foo.com", 3)
+		notifier.Check(ctx)
+		statuser.set("foo.com", 365)
+		notifier.Check(ctx)
+		notifier.Check(ctx)
+		require.Equal(t, []Level{LevelWarning, LevelCritical, LevelOK}, hook.levels())
+	})
+}
+
+func TestLevel(t *testing.T) {
+	require.Equal(t, LevelOK, levelOf(safeconfig.Domain{}, 45))
+	require.Equal(t, LevelWarning, levelOf(safeconfig.Domain{}, 30))
+	require.Equal(t, LevelCritical, levelOf(safeconfig.Domain{}, 7))
+	require.Equal(t, LevelCritical, levelOf(safeconfig.Domain{}, -1))
+	require.Equal(t, LevelWarning, levelOf(safeconfig.Domain{WarningDays: 60}, 45))
+	require.Equal(t, LevelCritical, levelOf(safeconfig.Domain{WarningDays: 60, CriticalDays: 45}, 45))
+}
+
+func TestRetryFailed(t *testing.T) {
+	hook := &recorder{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	statuser := fakeStatuser{}
+	statuser.set("foo.com", 3)
+	notifier, err := New(safeconfig.Notifications{Webhooks: []string{srv.URL}}, statuser, safeconfig.Domain{Name: "foo.com"})
+	require.NoError(t, err)
+
+	notifier.Check(context.Background())
+	require.Empty(t, hook.levels())
+
+	hook.mutex.Lock()
+	hook.status = 0
+	hook.mutex.Unlock()
+	notifier.Check(context.Background())
+	require.Equal(t, []Level{LevelCritical}, hook.levels())
+}
+
+func TestState(t *testing.T) {
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	statuser := fakeStatuser{}
+	statuser.set("foo.com", 3)
+	cfg := safeconfig.Notifications{
+		Webhooks:  []string{srv.URL},
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+	}
+	notifier, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"})
+	require.NoError(t, err)
+	notifier.Check(context.Background())
+	require.Equal(t, []Level{LevelCritical}, hook.levels())
+
+	restarted, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]Level{
+		"foo.com": {senderKey("webhook", srv.URL): LevelCritical},
+	}, restarted.state)
+	restarted.Check(context.Background())
+	require.Equal(t, []Level{LevelCritical}, hook.levels())
+
+	t.Run("invalid", func(t *testing.T) {
+		cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(cfg.StateFile, []byte(`{"foo.com":"critical"}`), 0o600))
+		_, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"})
+		require.ErrorContains(t, err, "failed to parse notifier state")
+	})
+}
+
+func TestPartialFailure(t *testing.T) {
+	ok := &recorder{}
+	okSrv := httptest.NewServer(ok)
+	defer okSrv.Close()
+	failing := &recorder{status: http.StatusInternalServerError}
+	failingSrv := httptest.NewServer(failing)
+	defer failingSrv.Close()
+
+	statuser := fakeStatuser{}
+	statuser.set("foo.com", 3)
+	cfg := safeconfig.Notifications{Webhooks: []string{okSrv.URL, failingSrv.URL}}
+	notifier, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"})
+	require.NoError(t, err)
+
+	notifier.Check(context.Background())
+	require.Equal(t, []Level{LevelCritical}, ok.levels())
+	require.Empty(t, failing.levels())
+
+	failing.mutex.Lock()
+	failing.status = 0
+	failing.mutex.Unlock()
+	notifier.Check(context.Background())
+	require.Equal(t, []Level{LevelCritical}, ok.levels())
+	require.Equal(t, []Level{LevelCritical}, failing.levels())
+}
+
+func TestRunChecksOnStart(t *testing.T) {
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	statuser := fakeStatuser{}
+	statuser.set("foo.com", 3)
+	cfg := safeconfig.Notifications{Interval: time.Hour, Webhooks: []string{srv.URL}}
+	notifier, err := New(cfg, statuser, safeconfig.Domain{Name: "foo.com"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(hook.levels()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+// blockingClient returns an expiry in 3 days once released.
+type blockingClient chan struct{}
+
+func (c blockingClient) Lookup(ctx context.Context, _ string, _ string) (client.Result, error) {
+	select {
+	case <-c:
+		return client.Result{Expiry: time.Now().Add(3*24*time.Hour + time.Hour)}, nil
+	case <-ctx.Done():
+		return client.Result{}, ctx.Err()
+	}
+}
+
+func TestRunWaitsForRefresh(t *testing.T) {
+	hook := &recorder{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	domain := safeconfig.Domain{Name: "foo.com"}
+	backend := make(blockingClient)
+	fresh := refresher.New(time.Hour, backend, time.Minute, domain)
+	defer fresh.Stop()
+	cfg := safeconfig.Notifications{Interval: time.Hour, Webhooks: []string{srv.URL}}
+	notifier, err := New(cfg, fresh, domain)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Go(func() { fresh.Run(ctx) })
+	wg.Go(func() { notifier.Run(ctx) })
+
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, hook.levels())
+	close(backend)
+	require.Eventually(t, func() bool { return len(hook.levels()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	wg.Wait()
+}
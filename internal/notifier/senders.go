@@ -0,0 +1,117 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+)
+
+// sendTimeout bounds each notification request, as checks wait for them.
+const sendTimeout = 10 * time.Second
+
+// Sender sends notifications somewhere.
+type Sender interface {
+	Send(ctx context.Context, notification Notification) error
+}
+
+// newSenders returns the senders set in the config, by their keys.
+func newSenders(cfg safeconfig.Notifications) map[string]Sender {
+	senders := map[string]Sender{}
+	for _, url := range cfg.Webhooks {
+		senders[senderKey("webhook", url)] = webhook{url: url}
+	}
+	for _, url := range cfg.Slack {
+		senders[senderKey("slack", url)] = slack{url: url}
+	}
+	if cfg.SMTP.Addr != "" {
+		senders[senderKey("smtp", cfg.SMTP.Addr+" "+strings.Join(cfg.SMTP.To, ","))] = mailer(cfg.SMTP)
+	}
+	return senders
+}
+
+// senderKey identifies a sender in the state across restarts. The target is
+// hashed, as webhook URLs often carry secrets.
+func senderKey(kind, target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return kind + ":" + hex.EncodeToString(sum[:8])
+}
+
+// webhook posts the notification as JSON.
+type webhook struct {
+	url string
+}
+
+func (w webhook) Send(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, w.url, notification)
+}
+
+// slack posts the notification to a Slack-compatible incoming webhook.
+type slack struct {
+	url string
+}
+
+func (s slack) Send(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, s.url, map[string]string{
+		"text": fmt.Sprintf("[%s] %s", notification.Level, notification.Message),
+	})
+}
+
+func postJSON(ctx context.Context, url string, payload any) error {
+	bts, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bts))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to send notification: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// mailer sends the notification by email.
+type mailer safeconfig.SMTP
+
+func (m mailer) Send(_ context.Context, notification Notification) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp addr %q: %w", m.Addr, err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + strings.Join(m.To, ", "),
+		fmt.Sprintf("Subject: [%s] %s", notification.Level, notification.Message),
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		notification.Message,
+		"",
+	}, "\r\n")
+	if err := smtp.SendMail(m.Addr, auth, m.From, m.To, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send notification email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP is an SMTP server that accepts a single message.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+// fakeWebhook is an HTTP server that accepts JSON bodies.
+func fakeWebhook(t *testing.T) (string, <-chan map[string]any) {
+	t.Helper()
+	bodies := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, bodies
+}
+
+func TestSenders(t *testing.T) {
+	notification := Notification{
+		Domain:   "foo.com",
+		Level:    LevelWarning,
+		Previous: LevelOK,
+		Expiry:   time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		DaysLeft: 20,
+		Message:  "foo.com expires in 20 days, on 2030-01-02",
+	}
+
+	webhookURL, webhooks := fakeWebhook(t)
+	slackURL, slacks := fakeWebhook(t)
+	addr, messages := fakeSMTP(t)
+
+	senders := newSenders(safeconfig.Notifications{
+		Webhooks: []string{webhookURL},
+		Slack:    []string{slackURL},
+		SMTP:     safeconfig.SMTP{Addr: addr, From: "exporter@foo.com", To: []string{"ops@foo.com", "dev@foo.com"}},
+	})
+	require.Len(t, senders, 3)
+	for _, sender := range senders {
+		require.NoError(t, sender.Send(context.Background(), notification))
+	}
+
+	webhook := <-webhooks
+	require.Equal(t, "foo.com", webhook["domain"])
+	require.Equal(t, "warning", webhook["level"])
+	require.Equal(t, "2030-01-02T00:00:00Z", webhook["expiry"])
+	require.InDelta(t, 20, webhook["days_left"], 0)
+
+	slack := <-slacks
+	require.Equal(t, map[string]any{"text": "[warning] foo.com expires in 20 days, on 2030-01-02"}, slack)
+
+	msg := <-messages
+	require.Contains(t, msg, "To: ops@foo.com, dev@foo.com\r\n")
+	require.Contains(t, msg, "Subject: [warning] foo.com expires in 20 days, on 2030-01-02\r\n")
+}
+
+func TestSenderErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := webhook{url: srv.URL}.Send(context.Background(), Notification{})
+	require.EqualError(t, err, "failed to send notification: unexpected status 403 Forbidden")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	err = mailer{Addr: addr, From: "a@foo.com", To: []string{"b@foo.com"}}.Send(context.Background(), Notification{})
+	require.ErrorContains(t, err, "failed to send notification email")
+}
@@ -2,10 +2,14 @@ package rdap
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
 	"github.com/openrdap/rdap"
 	"github.com/rs/zerolog/log"
 )
@@ -40,46 +44,91 @@ var (
 		"2006/01/02",               // .ca
 		"2006-01-02 (YYYY-MM-DD)",  // .tw
 		"(dd/mm/yyyy): 02/01/2006", // .pt
-		"02-Jan-2006 15:04:05 UTC", // .id, .co.id
+		"02-Jan-2006 15:04:05 MST", // .id, .co.id
 		": 2006. 01. 02.",          // .kr
 	}
 )
 
-type rdapClient struct{}
+type rdapClient struct {
+	// server overrides the bootstrapped RDAP server, used in tests.
+	server *url.URL
+}
 
 // NewClient returns a new RDAP client.
 func NewClient() client.Client {
 	return rdapClient{}
 }
 
-func (rdapClient) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
+func (c rdapClient) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
 	log.Debug().Msgf("trying rdap client for %s", domain)
 	req := &rdap.Request{
-		Type:  rdap.DomainRequest,
-		Query: domain,
+		Type:   rdap.DomainRequest,
+		Query:  domain,
+		Server: c.server,
 	}
 	req = req.WithContext(ctx)
 
-	client := &rdap.Client{}
-	resp, err := client.Do(req)
+	cli := &rdap.Client{}
+	resp, err := cli.Do(req)
+	traceResponses(client.TraceFrom(ctx), resp)
 	if err != nil {
-		return time.Now(), fmt.Errorf("failed to do rdap request: %w", err)
+		var clientErr *rdap.ClientError
+		if errors.As(err, &clientErr) && clientErr.Type == rdap.ObjectDoesNotExist {
+			return client.Result{}, fmt.Errorf("%w: failed to do rdap request: %w", client.ErrDomainNotFound, err)
+		}
+		if rateLimited(resp) {
+			return client.Result{}, fmt.Errorf("%w: failed to do rdap request: %w", client.ErrRateLimited, err)
+		}
+		return client.Result{}, client.Classify(fmt.Errorf("failed to do rdap request: %w", err))
 	}
 
 	body, ok := resp.Object.(*rdap.Domain)
 	if !ok {
-		return time.Now(), fmt.Errorf("failed to cast rdap domain object: %w", err)
+		return client.Result{}, fmt.Errorf("failed to cast rdap domain object: %w", err)
 	}
 
 	for _, event := range body.Events {
 		if event.Action == "expiration" {
-			for _, format := range formats {
-				if date, err := time.Parse(format, event.Date); err == nil {
-					return date, nil
-				}
+			date, layout, err := dateparse.Parse(event.Date, formats, dateparse.Location(domain))
+			if err != nil {
+				return client.Result{}, fmt.Errorf("%w: %w", client.ErrBadDate, err)
 			}
-			return time.Now(), fmt.Errorf("could not parse date: %s", event.Date)
+			client.TraceFrom(ctx).Add("rdap", "layout", fmt.Sprintf("%q parsed %q as %s", layout, event.Date, date))
+			return client.Result{
+				Expiry:         date,
+				Confidence:     dateparse.ConfidenceExact,
+				RegistryExpiry: date,
+			}, nil
+		}
+	}
+	return client.Result{}, fmt.Errorf("%w: no expiration event for domain: %s ", client.ErrNoExpiry, domain)
+}
+
+// traceResponses records the requests made and the raw responses.
+func traceResponses(trace *client.Trace, resp *rdap.Response) {
+	if trace == nil || resp == nil {
+		return
+	}
+	for _, r := range resp.HTTP {
+		trace.Add("rdap", "request", r.URL)
+		if r.Error != nil {
+			trace.Add("rdap", "error", r.Error.Error())
+		}
+		if len(r.Body) > 0 {
+			trace.Add("rdap", "response", string(r.Body))
+		}
+	}
+}
+
+// rateLimited reports whether any of the RDAP servers answered with 429.
+func rateLimited(resp *rdap.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, r := range resp.HTTP {
+		if r.Response != nil && r.Response.StatusCode == http.StatusTooManyRequests {
+			return true
 		}
 	}
-	return time.Now(), fmt.Errorf("no expiration event for domain: %s ", domain)
+	return false
 }
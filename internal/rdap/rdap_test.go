@@ -2,9 +2,14 @@ package rdap
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,13 +38,96 @@ func TestRdapParsing(t *testing.T) {
 	} {
 		t.Run(tt.domain, func(t *testing.T) {
 			t.Parallel()
-			expiry, err := NewClient().ExpireTime(context.Background(), tt.domain, "")
+			result, err := NewClient().Lookup(context.Background(), tt.domain, "")
 			if tt.err == "" {
 				require.NoError(t, err)
-				require.Less(t, time.Since(expiry).Hours(), 0.0)
+				require.Less(t, time.Since(result.Expiry).Hours(), 0.0)
 			} else {
 				require.ErrorContains(t, err, tt.err)
 			}
 		})
 	}
 }
+
+func TestFormats(t *testing.T) {
+	for _, tt := range []struct {
+		domain string
+		value  string
+		expect string
+	}{
+		{domain: "google.com", value: "2030-01-02T15:04:05Z", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com", value: "2030-01-02T15:04:05.123Z", expect: "2030-01-02T15:04:05.123Z"},
+		{domain: "google.com", value: "2030-01-02T15:04:05+08:00", expect: "2030-01-02T07:04:05Z"},
+		{domain: "google.com", value: "Wed, 02 Jan 2030 15:04:05 GMT", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com.br", value: "20300102", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.lt", value: "2030-01-02", expect: "2030-01-02T00:00:00Z"},
+		{domain: "nic.ua", value: "2030-01-02 15:04:05+02", expect: "2030-01-02T13:04:05Z"},
+		{domain: "google.ch", value: "2030-07-02 15:04:05", expect: "2030-07-02T13:04:05Z"},
+		{domain: "google.is", value: "January  2 2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.cz", value: "02.01.2030", expect: "2030-01-01T23:00:00Z"},
+		{domain: "google.ie", value: "02-January-2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.pl", value: "2030.01.02 15:04:05", expect: "2030-01-02T14:04:05Z"},
+		{domain: "google.sg", value: "02-Jan-2030 15:04:05", expect: "2030-01-02T07:04:05Z"},
+		{domain: "taiwannews.com.tw", value: "2030-01-02 (YYYY-MM-DD)", expect: "2030-01-01T16:00:00Z"},
+		{domain: "google.co.id", value: "02-Jan-2030 15:04:05 UTC", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.kr", value: ": 2030. 01. 02.", expect: "2030-01-01T15:00:00Z"},
+	} {
+		t.Run(tt.domain+" "+tt.value, func(t *testing.T) {
+			expect, err := time.Parse(time.RFC3339Nano, tt.expect)
+			require.NoError(t, err)
+			date, _, err := dateparse.Parse(tt.value, formats, dateparse.Location(tt.domain))
+			require.NoError(t, err)
+			require.Equal(t, expect, date)
+		})
+	}
+}
+
+func TestFakeServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rdap+json")
+		switch r.URL.Path {
+		case "/domain/example.com":
+			_, _ = w.Write([]byte(`{
+				"objectClassName": "domain",
+				"ldhName": "EXAMPLE.COM",
+				"events": [
+					{"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+					{"eventAction": "expiration", "eventDate": "2030-08-13T04:00:00Z"}
+				]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	server, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	cli := rdapClient{server: server}
+
+	t.Run("found", func(t *testing.T) {
+		trace := &client.Trace{}
+		result, err := cli.Lookup(client.WithTrace(context.Background(), trace), "example.com", "")
+		require.NoError(t, err)
+		expect := time.Date(2030, 8, 13, 4, 0, 0, 0, time.UTC)
+		require.Equal(t, expect, result.Expiry)
+		require.Equal(t, expect, result.RegistryExpiry)
+
+		events := trace.Events()
+		require.Len(t, events, 3)
+		require.Equal(t, "request", events[0].Step)
+		require.Equal(t, srv.URL+"/domain/example.com", events[0].Value)
+		require.Equal(t, "response", events[1].Step)
+		require.Contains(t, events[1].Value, `"ldhName": "EXAMPLE.COM"`)
+		require.Equal(t, client.TraceEvent{
+			Backend: "rdap",
+			Step:    "layout",
+			Value:   `"2006-01-02T15:04:05Z07:00" parsed "2030-08-13T04:00:00Z" as 2030-08-13 04:00:00 +0000 UTC`,
+		}, events[2])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := cli.Lookup(context.Background(), "fakedomain.com", "")
+		require.ErrorIs(t, err, client.ErrDomainNotFound)
+		require.ErrorContains(t, err, "RDAP server returned 404, object does not exist.")
+	})
+}
@@ -2,6 +2,9 @@ package refresher
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/caarlos0/domain_exporter/internal/client"
@@ -9,48 +12,158 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Status is the state of a domain as last seen by the refresher.
+type Status struct {
+	Domain safeconfig.Domain
+	// Result is the result of the last successful lookup.
+	Result      client.Result
+	LastSuccess time.Time
+	LastAttempt time.Time
+	// Duration is how long the last lookup took.
+	Duration time.Duration
+	// LastError is the error of the last lookup, if it failed.
+	LastError error
+}
+
 type Refresher struct {
 	ticker  *time.Ticker
 	client  client.Client
 	domains []safeconfig.Domain
 	timeout time.Duration
+
+	mutex    sync.RWMutex
+	statuses map[string]Status
+
+	// refreshed is closed once the first refresh is done.
+	refreshed chan struct{}
+	once      sync.Once
 }
 
-func New(interval time.Duration, client client.Client, timeout time.Duration, domains ...safeconfig.Domain) Refresher {
+func New(interval time.Duration, client client.Client, timeout time.Duration, domains ...safeconfig.Domain) *Refresher {
 	ticker := time.NewTicker(interval)
-	return Refresher{
-		ticker:  ticker,
-		client:  client,
-		domains: domains,
-		timeout: timeout,
+	statuses := map[string]Status{}
+	for _, domain := range domains {
+		statuses[domain.Name] = Status{Domain: domain}
+	}
+	return &Refresher{
+		ticker:    ticker,
+		client:    client,
+		domains:   domains,
+		timeout:   timeout,
+		statuses:  statuses,
+		refreshed: make(chan struct{}),
 	}
 }
 
-func (r Refresher) Stop() {
+func (r *Refresher) Stop() {
 	r.ticker.Stop()
 }
 
-func (r Refresher) Run(ctx context.Context) {
+func (r *Refresher) Run(ctx context.Context) {
 	log.Info().Msg("run refresher")
 	r.Refresh(ctx)
 
-	select {
-	case <-r.ticker.C:
-		r.Refresh(ctx)
-	case <-ctx.Done():
-		log.Info().Msg("refresher is finished")
-		return
+	for {
+		select {
+		case <-r.ticker.C:
+			r.Refresh(ctx)
+		case <-ctx.Done():
+			log.Info().Msg("refresher is finished")
+			return
+		}
 	}
 }
 
-func (r Refresher) Refresh(ctx context.Context) {
+func (r *Refresher) Refresh(ctx context.Context) {
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
 	for _, domain := range r.domains {
-		if _, err := r.client.ExpireTime(ctx, domain.Name, domain.Host); err != nil {
-			log.Error().Err(err).Msgf("failed to get expire time for %s", domain)
+		if _, err := r.lookup(ctx, domain); err != nil {
+			log.Error().Err(err).Msgf("failed to get expire time for %v", domain)
 		}
 	}
 	log.Debug().Msg("refresh is done")
+	r.once.Do(func() { close(r.refreshed) })
+}
+
+// Refreshed returns a channel that is closed once the first refresh of all
+// the domains is done.
+func (r *Refresher) Refreshed() <-chan struct{} {
+	return r.refreshed
+}
+
+// RefreshDomain looks up a single domain right away. If host is empty, the
+// host configured for the domain, if any, is used.
+func (r *Refresher) RefreshDomain(ctx context.Context, name, host string) (client.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	domain := safeconfig.Domain{Name: name}
+	for _, configured := range r.domains {
+		if configured.Name == name {
+			domain = configured
+			break
+		}
+	}
+	if host != "" {
+		domain.Host = host
+	}
+	result, err := r.lookup(ctx, domain)
+	if err != nil {
+		return client.Result{}, fmt.Errorf("failed to refresh %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// lookup looks the domain up, recording its status.
+func (r *Refresher) lookup(ctx context.Context, domain safeconfig.Domain) (client.Result, error) {
+	start := time.Now()
+	result, err := r.client.Lookup(ctx, domain.Name, domain.Host)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	status := r.statuses[domain.Name]
+	status.Domain = domain
+	status.LastAttempt = time.Now()
+	status.Duration = status.LastAttempt.Sub(start)
+	status.LastError = err
+	if err == nil {
+		status.Result = result
+		status.LastSuccess = status.LastAttempt
+	}
+	r.statuses[domain.Name] = status
+	return result, err
+}
+
+// Statuses returns the status of the configured domains, in order, followed
+// by the ones refreshed on demand, by name.
+func (r *Refresher) Statuses() []Status {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	statuses := make([]Status, 0, len(r.statuses))
+	configured := map[string]bool{}
+	for _, domain := range r.domains {
+		configured[domain.Name] = true
+		statuses = append(statuses, r.statuses[domain.Name])
+	}
+	var others []Status
+	for name, status := range r.statuses {
+		if !configured[name] {
+			others = append(others, status)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].Domain.Name < others[j].Domain.Name
+	})
+	return append(statuses, others...)
+}
+
+// Status returns the status of the given domain.
+func (r *Refresher) Status(name string) (Status, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	status, ok := r.statuses[name]
+	return status, ok
 }
@@ -6,25 +6,27 @@ import (
 	"testing"
 	"time"
 
+	"github.com/caarlos0/domain_exporter/internal/client"
 	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeOk struct{}
 
-func (fakeOk) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
-	return time.Time{}, nil
+func (fakeOk) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
+	return client.Result{}, nil
 }
 
 type fakeFail struct{}
 
-func (fakeFail) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
-	return time.Time{}, errors.New("foo")
+func (fakeFail) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
+	return client.Result{}, errors.New("foo")
 }
 
 func Test_refresher_Refresh(t *testing.T) {
 	tests := []struct {
 		name      string
-		refresher Refresher
+		refresher *Refresher
 	}{
 		{
 			name:      "refresh is ok",
@@ -42,3 +44,119 @@ func Test_refresher_Refresh(t *testing.T) {
 		})
 	}
 }
+
+type fakeHosts map[string]string
+
+func (f fakeHosts) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
+	f[domain] = host
+	return client.Result{WhoisServer: host}, nil
+}
+
+func TestRefreshDomain(t *testing.T) {
+	hosts := fakeHosts{}
+	refresher := New(time.Second, hosts, time.Second, safeconfig.Domain{Name: "foo.com", Host: "whois.foo"})
+	defer refresher.Stop()
+
+	_, err := refresher.RefreshDomain(context.Background(), "foo.com", "")
+	require.NoError(t, err)
+	_, err = refresher.RefreshDomain(context.Background(), "bar.com", "whois.bar")
+	require.NoError(t, err)
+	require.Equal(t, fakeHosts{"foo.com": "whois.foo", "bar.com": "whois.bar"}, hosts)
+
+	_, err = New(time.Second, fakeFail{}, time.Second).RefreshDomain(context.Background(), "foo.com", "")
+	require.EqualError(t, err, "failed to refresh foo.com: foo")
+}
+
+func TestRefreshDomainHostOverride(t *testing.T) {
+	hosts := fakeHosts{}
+	configured := safeconfig.Domain{Name: "foo.com", Host: "whois.foo", WarningDays: 60, CriticalDays: 14}
+	refresher := New(time.Second, hosts, time.Second, configured)
+	defer refresher.Stop()
+
+	_, err := refresher.RefreshDomain(context.Background(), "foo.com", "whois.other")
+	require.NoError(t, err)
+	require.Equal(t, fakeHosts{"foo.com": "whois.other"}, hosts)
+
+	status, ok := refresher.Status("foo.com")
+	require.True(t, ok)
+	configured.Host = "whois.other"
+	require.Equal(t, configured, status.Domain)
+}
+
+func TestRun(t *testing.T) {
+	var count int
+	refresher := New(time.Millisecond, countingClient{client: fakeOk{}, count: &count}, time.Second, safeconfig.Domain{Name: "foo.com"})
+	defer refresher.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	refresher.Run(ctx)
+	require.Greater(t, count, 2)
+}
+
+func TestRefreshed(t *testing.T) {
+	refresher := New(time.Hour, fakeFail{}, time.Second, safeconfig.Domain{Name: "foo.com"})
+	defer refresher.Stop()
+
+	select {
+	case <-refresher.Refreshed():
+		t.Fatal("refreshed before the first refresh")
+	default:
+	}
+	refresher.Refresh(context.Background())
+	refresher.Refresh(context.Background())
+	select {
+	case <-refresher.Refreshed():
+	default:
+		t.Fatal("not refreshed after the first refresh")
+	}
+}
+
+type countingClient struct {
+	client client.Client
+	count  *int
+}
+
+func (c countingClient) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
+	*c.count++
+	return c.client.Lookup(ctx, domain, host)
+}
+
+func TestStatuses(t *testing.T) {
+	hosts := fakeHosts{}
+	refresher := New(time.Second, hosts, time.Second, safeconfig.Domain{Name: "foo.com"}, safeconfig.Domain{Name: "bar.com"})
+	defer refresher.Stop()
+
+	before := time.Now()
+	refresher.Refresh(context.Background())
+	_, err := refresher.RefreshDomain(context.Background(), "adhoc.com", "whois.adhoc")
+	require.NoError(t, err)
+
+	statuses := refresher.Statuses()
+	require.Len(t, statuses, 3)
+	require.Equal(t, "foo.com", statuses[0].Domain.Name)
+	require.Equal(t, "bar.com", statuses[1].Domain.Name)
+	require.Equal(t, safeconfig.Domain{Name: "adhoc.com", Host: "whois.adhoc"}, statuses[2].Domain)
+	require.Equal(t, "whois.adhoc", statuses[2].Result.WhoisServer)
+	for _, status := range statuses {
+		require.NoError(t, status.LastError)
+		require.False(t, status.LastSuccess.Before(before))
+		require.Equal(t, status.LastSuccess, status.LastAttempt)
+	}
+
+	failing := New(time.Second, fakeFail{}, time.Second, safeconfig.Domain{Name: "foo.com"})
+	defer failing.Stop()
+	status, ok := failing.Status("foo.com")
+	require.True(t, ok)
+	require.True(t, status.LastAttempt.IsZero())
+
+	failing.Refresh(context.Background())
+	status, ok = failing.Status("foo.com")
+	require.True(t, ok)
+	require.EqualError(t, status.LastError, "foo")
+	require.True(t, status.LastSuccess.IsZero())
+	require.False(t, status.LastAttempt.IsZero())
+
+	_, ok = failing.Status("bar.com")
+	require.False(t, ok)
+}
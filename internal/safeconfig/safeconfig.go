@@ -4,14 +4,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 )
 
+// Default expiry thresholds, used when neither the domain, its group nor the
+// config set one.
+const (
+	DefaultWarningDays  = 30
+	DefaultCriticalDays = 7
+)
+
 type Domain struct {
 	Name string `yaml:"name"`
 	Host string `yaml:"host,omitempty"`
+	// WarningDays and CriticalDays are how many days before expiring the
+	// domain needs attention.
+	WarningDays  int `yaml:"warning_days,omitempty"`
+	CriticalDays int `yaml:"critical_days,omitempty"`
 }
 
 type domainAlias Domain
@@ -31,8 +44,211 @@ func (a *Domain) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// Thresholds returns the warning and critical thresholds of the domain, in
+// days, using the defaults for the unset ones. The default critical one is
+// capped at the warning one.
+func (a Domain) Thresholds() (warning, critical int) {
+	warning, critical = a.WarningDays, a.CriticalDays
+	if warning == 0 {
+		warning = DefaultWarningDays
+	}
+	if critical == 0 {
+		critical = min(DefaultCriticalDays, warning)
+	}
+	return warning, critical
+}
+
+// withThresholds returns the domain with its unset thresholds set to the
+// given ones.
+func (a Domain) withThresholds(warning, critical int) Domain {
+	if a.WarningDays == 0 {
+		a.WarningDays = warning
+	}
+	if a.CriticalDays == 0 {
+		a.CriticalDays = critical
+	}
+	return a
+}
+
+// Group is a named list of domains, which may share thresholds. It can be
+// written as just the list of domains.
+type Group struct {
+	WarningDays  int      `yaml:"warning_days,omitempty"`
+	CriticalDays int      `yaml:"critical_days,omitempty"`
+	Domains      []Domain `yaml:"domains"`
+}
+
+func (g *Group) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var domains []Domain
+	if err := unmarshal(&domains); err == nil {
+		*g = Group{Domains: domains}
+		return nil
+	}
+
+	type groupAlias Group
+	var ga groupAlias
+	if err := unmarshal(&ga); err != nil {
+		return err
+	}
+	*g = Group(ga)
+	return nil
+}
+
+// Module is a named set of probe options, selected with the module
+// parameter of /probe. Unset options use the command line flags.
+type Module struct {
+	// Protocols are the backends to use, in order: rdap and/or whois.
+	Protocols    []string      `yaml:"protocols,omitempty"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	Strategy     string        `yaml:"strategy,omitempty"`
+	ExpiryPolicy string        `yaml:"expiry_policy,omitempty"`
+	Referral     *bool         `yaml:"referral,omitempty"`
+	MaxReferrals int           `yaml:"max_referrals,omitempty"`
+}
+
+// WithDefaults returns the module with its unset options taken from the
+// given defaults.
+func (m Module) WithDefaults(defaults Module) Module {
+	if len(m.Protocols) == 0 {
+		m.Protocols = defaults.Protocols
+	}
+	if m.Timeout == 0 {
+		m.Timeout = defaults.Timeout
+	}
+	if m.Strategy == "" {
+		m.Strategy = defaults.Strategy
+	}
+	if m.ExpiryPolicy == "" {
+		m.ExpiryPolicy = defaults.ExpiryPolicy
+	}
+	if m.Referral == nil {
+		m.Referral = defaults.Referral
+	}
+	if m.MaxReferrals == 0 {
+		m.MaxReferrals = defaults.MaxReferrals
+	}
+	return m
+}
+
+func (m Module) validate() error {
+	for _, protocol := range m.Protocols {
+		if !slices.Contains([]string{"rdap", "whois"}, protocol) {
+			return fmt.Errorf("invalid protocol %q, must be rdap or whois", protocol)
+		}
+	}
+	if m.Strategy != "" && !slices.Contains([]string{"sequential", "race", "consensus"}, m.Strategy) {
+		return fmt.Errorf("invalid strategy %q, must be sequential, race or consensus", m.Strategy)
+	}
+	if m.ExpiryPolicy != "" && !slices.Contains([]string{"registry", "registrar", "min", "max"}, m.ExpiryPolicy) {
+		return fmt.Errorf("invalid expiry policy %q, must be registry, registrar, min or max", m.ExpiryPolicy)
+	}
+	if m.Timeout < 0 || m.MaxReferrals < 0 {
+		return fmt.Errorf("timeout and max_referrals must not be negative")
+	}
+	return nil
+}
+
+// Notifications configures the built-in notifier, which is disabled unless
+// a webhook, a Slack webhook or SMTP is set.
+type Notifications struct {
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// StateFile keeps the notifications sent across restarts.
+	StateFile string   `yaml:"state_file,omitempty"`
+	Webhooks  []string `yaml:"webhooks,omitempty"`
+	Slack     []string `yaml:"slack,omitempty"`
+	SMTP      SMTP     `yaml:"smtp,omitempty"`
+}
+
+// SMTP configures notifications by email.
+type SMTP struct {
+	// Addr is the host:port of the server.
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// Enabled returns whether notifications are sent anywhere.
+func (n Notifications) Enabled() bool {
+	return len(n.Webhooks) != 0 || len(n.Slack) != 0 || n.SMTP.Addr != ""
+}
+
+func (n Notifications) validate() error {
+	if n.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	if n.SMTP.Addr != "" && (n.SMTP.From == "" || len(n.SMTP.To) == 0) {
+		return fmt.Errorf("smtp needs from and to")
+	}
+	return nil
+}
+
 type SafeConfig struct {
-	Domains []Domain `yaml:"domains"`
+	Domains []Domain          `yaml:"domains"`
+	Modules map[string]Module `yaml:"modules"`
+	// Groups are named lists of domains, probed together with the group
+	// parameter of /probe.
+	Groups        map[string]Group `yaml:"groups"`
+	Notifications Notifications    `yaml:"notifications"`
+	// WarningDays and CriticalDays are the thresholds of the domains that
+	// set none, in days.
+	WarningDays  int `yaml:"warning_days,omitempty"`
+	CriticalDays int `yaml:"critical_days,omitempty"`
+}
+
+// validateThresholds checks the thresholds of a domain, group or config,
+// where 0 means unset.
+func validateThresholds(warning, critical int) error {
+	if warning < 0 || critical < 0 {
+		return fmt.Errorf("warning_days and critical_days must not be negative")
+	}
+	if warning != 0 && critical > warning {
+		return fmt.Errorf("critical_days must not be greater than warning_days")
+	}
+	return nil
+}
+
+func (cfg SafeConfig) validate() error {
+	for name, module := range cfg.Modules {
+		if err := module.validate(); err != nil {
+			return fmt.Errorf("invalid module %s: %w", name, err)
+		}
+	}
+	if err := cfg.Notifications.validate(); err != nil {
+		return fmt.Errorf("invalid notifications: %w", err)
+	}
+	if err := validateThresholds(cfg.WarningDays, cfg.CriticalDays); err != nil {
+		return err
+	}
+	domains := slices.Clone(cfg.Domains)
+	for name, group := range cfg.Groups {
+		if err := validateThresholds(group.WarningDays, group.CriticalDays); err != nil {
+			return fmt.Errorf("invalid group %s: %w", name, err)
+		}
+		domains = append(domains, group.Domains...)
+	}
+	for _, domain := range domains {
+		if err := validateThresholds(domain.Thresholds()); err != nil {
+			return fmt.Errorf("invalid domain %s: %w", domain.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyThresholds sets the unset thresholds of the domains to the ones of
+// their group, or else of the config.
+func (cfg *SafeConfig) applyThresholds() {
+	for i, domain := range cfg.Domains {
+		cfg.Domains[i] = domain.withThresholds(cfg.WarningDays, cfg.CriticalDays)
+	}
+	for _, group := range cfg.Groups {
+		for i, domain := range group.Domains {
+			group.Domains[i] = domain.
+				withThresholds(group.WarningDays, group.CriticalDays).
+				withThresholds(cfg.WarningDays, cfg.CriticalDays)
+		}
+	}
 }
 
 func New(pathToFile string) (SafeConfig, error) {
@@ -62,11 +278,18 @@ func (cfg *SafeConfig) Reload(pathToFile string) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	err = yaml.Unmarshal(yamlFile, cfg)
+	loaded := SafeConfig{}
+	err = yaml.Unmarshal(yamlFile, &loaded)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal file: %w", err)
 	}
 
-	log.Debug().Msgf("config file is loaded:\n %s", *cfg)
+	loaded.applyThresholds()
+	if err := loaded.validate(); err != nil {
+		return err
+	}
+	*cfg = loaded
+
+	log.Debug().Msgf("config file is loaded:\n %v", *cfg)
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -50,6 +51,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestSafeConfig_Reload(t *testing.T) {
+	enabled := true
 	tests := []struct {
 		name        string
 		cfg         SafeConfig
@@ -78,6 +80,153 @@ domains:
 - google.com`,
 			wantErr: false,
 		},
+		{
+			name: "modules",
+			cfg: SafeConfig{
+				Modules: map[string]Module{
+					"fast_rdap":  {Protocols: []string{"rdap"}, Timeout: 5 * time.Second},
+					"slow_cctld": {Protocols: []string{"whois"}, Timeout: 30 * time.Second, Referral: &enabled, MaxReferrals: 5},
+				},
+			},
+			fileContent: `
+modules:
+  fast_rdap:
+    protocols: [rdap]
+    timeout: 5s
+  slow_cctld:
+    protocols: [whois]
+    timeout: 30s
+    referral: true
+    max_referrals: 5`,
+			wantErr: false,
+		},
+		{
+			name: "groups",
+			cfg: SafeConfig{
+				Groups: map[string]Group{
+					"payments": {Domains: []Domain{{Name: "pay.com"}, {Name: "checkout.com", Host: "whois.checkout"}}},
+				},
+			},
+			fileContent: `
+groups:
+  payments:
+  - pay.com
+  - name: checkout.com
+    host: whois.checkout`,
+			wantErr: false,
+		},
+		{
+			name: "thresholds",
+			cfg: SafeConfig{
+				WarningDays: 45,
+				Domains: []Domain{
+					{Name: "foo.com", WarningDays: 45},
+					{Name: "bar.com", WarningDays: 60, CriticalDays: 14},
+					{Name: "baz.com", WarningDays: 90},
+				},
+				Groups: map[string]Group{
+					"payments": {
+						WarningDays:  60,
+						CriticalDays: 21,
+						Domains: []Domain{
+							{Name: "pay.com", WarningDays: 60, CriticalDays: 21},
+							{Name: "checkout.com", WarningDays: 90, CriticalDays: 21},
+						},
+					},
+					"blogs": {Domains: []Domain{{Name: "blog.com", WarningDays: 45}}},
+				},
+			},
+			fileContent: `
+warning_days: 45
+domains:
+- foo.com
+- name: bar.com
+  warning_days: 60
+  critical_days: 14
+- name: baz.com
+  warning_days: 90
+groups:
+  payments:
+    warning_days: 60
+    critical_days: 21
+    domains:
+    - pay.com
+    - name: checkout.com
+      warning_days: 90
+  blogs:
+  - blog.com`,
+			wantErr: false,
+		},
+		{
+			name: "warning below the default critical",
+			cfg: SafeConfig{
+				WarningDays: 5,
+				Domains: []Domain{
+					{Name: "foo.com", WarningDays: 5},
+					{Name: "bar.com", WarningDays: 3},
+				},
+				Groups: map[string]Group{
+					"payments": {WarningDays: 4, Domains: []Domain{{Name: "pay.com", WarningDays: 4}}},
+				},
+			},
+			fileContent: `
+warning_days: 5
+domains:
+- foo.com
+- name: bar.com
+  warning_days: 3
+groups:
+  payments:
+    warning_days: 4
+    domains: [pay.com]`,
+			wantErr: false,
+		},
+		{
+			name:        "invalid thresholds",
+			cfg:         SafeConfig{},
+			fileContent: "domains: [{name: foo.com, warning_days: 7, critical_days: 30}]",
+			wantErr:     true,
+		},
+		{
+			name: "notifications",
+			cfg: SafeConfig{
+				Domains: []Domain{{Name: "pay.com"}},
+				Notifications: Notifications{
+					StateFile: "/var/lib/domain_exporter/notifications.json",
+					Slack:     []string{"https://hooks.slack.com/services/foo"},
+					SMTP:      SMTP{Addr: "smtp.foo:587", From: "exporter@foo", To: []string{"ops@foo"}},
+				},
+			},
+			fileContent: `
+domains:
+- pay.com
+notifications:
+  state_file: /var/lib/domain_exporter/notifications.json
+  slack: [https://hooks.slack.com/services/foo]
+  smtp:
+    addr: smtp.foo:587
+    from: exporter@foo
+    to: [ops@foo]`,
+			wantErr: false,
+		},
+		{
+			name:        "invalid notifications smtp",
+			cfg:         SafeConfig{},
+			fileContent: "notifications: {smtp: {addr: smtp.foo:25}}",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid module protocol",
+			cfg:         SafeConfig{},
+			fileContent: "modules: {foo: {protocols: [ftp]}}",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid module strategy",
+			cfg:         SafeConfig{},
+			fileContent: "modules: {foo: {strategy: random}}",
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,8 +262,51 @@ domains:
 			}
 
 			if !reflect.DeepEqual(cfg, tt.cfg) {
-				t.Errorf("cfg is not equal:\n got %s\n expected: %s", cfg, tt.cfg)
+				t.Errorf("cfg is not equal:\n got %v\n expected: %v", cfg, tt.cfg)
 			}
 		})
 	}
 }
+
+func TestModuleWithDefaults(t *testing.T) {
+	enabled, disabled := true, false
+	defaults := Module{
+		Protocols:    []string{"rdap", "whois"},
+		Timeout:      10 * time.Second,
+		Strategy:     "sequential",
+		ExpiryPolicy: "registry",
+		Referral:     &enabled,
+		MaxReferrals: 3,
+	}
+	if got := (Module{}).WithDefaults(defaults); !reflect.DeepEqual(got, defaults) {
+		t.Errorf("WithDefaults() = %v, want %v", got, defaults)
+	}
+
+	module := Module{Protocols: []string{"whois"}, Timeout: time.Second, Referral: &disabled}
+	want := Module{
+		Protocols:    []string{"whois"},
+		Timeout:      time.Second,
+		Strategy:     "sequential",
+		ExpiryPolicy: "registry",
+		Referral:     &disabled,
+		MaxReferrals: 3,
+	}
+	if got := module.WithDefaults(defaults); !reflect.DeepEqual(got, want) {
+		t.Errorf("WithDefaults() = %v, want %v", got, want)
+	}
+}
+
+func TestDomainThresholds(t *testing.T) {
+	warning, critical := Domain{Name: "foo.com"}.Thresholds()
+	if warning != DefaultWarningDays || critical != DefaultCriticalDays {
+		t.Errorf("Thresholds() = %d, %d, want defaults", warning, critical)
+	}
+	warning, critical = Domain{Name: "foo.com", WarningDays: 60, CriticalDays: 14}.Thresholds()
+	if warning != 60 || critical != 14 {
+		t.Errorf("Thresholds() = %d, %d, want 60, 14", warning, critical)
+	}
+	warning, critical = Domain{Name: "foo.com", WarningDays: 5}.Thresholds()
+	if warning != 5 || critical != 5 {
+		t.Errorf("Thresholds() = %d, %d, want 5, 5", warning, critical)
+	}
+}
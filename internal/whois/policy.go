@@ -0,0 +1,82 @@
+package whois
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/rs/zerolog/log"
+)
+
+// ExpiryPolicy decides which expiry date is authoritative when both the
+// registry and the registrar report one.
+type ExpiryPolicy string
+
+// Available expiry policies.
+const (
+	PolicyRegistry  ExpiryPolicy = "registry"
+	PolicyRegistrar ExpiryPolicy = "registrar"
+	PolicyMin       ExpiryPolicy = "min"
+	PolicyMax       ExpiryPolicy = "max"
+)
+
+// nolint: gochecknoglobals
+var (
+	registryExpiryRE  = regexp.MustCompile(`(?im)^[ \t]*Registry Expiry Date:[ \t]*(.*)$`)
+	registrarExpiryRE = regexp.MustCompile(`(?im)^[ \t]*Registrar Registration Expiration Date:[ \t]*(.*)$`)
+)
+
+// WithExpiryPolicy sets how to pick between the registry and registrar
+// expiry dates.
+func WithExpiryPolicy(policy ExpiryPolicy) Option {
+	return func(c *whoisClient) {
+		c.policy = policy
+	}
+}
+
+// sourceExpiry parses the registry and registrar expiry dates, if any.
+func (p parser) sourceExpiry(domain, body string) (registry, registrar client.Result) {
+	parse := func(re *regexp.Regexp) client.Result {
+		match := re.FindStringSubmatch(body)
+		if len(match) < 2 || strings.TrimSpace(match[1]) == "" {
+			return client.Result{}
+		}
+		p.trace.Add("whois", "expiry key", strings.TrimSpace(strings.TrimSuffix(match[0], match[1])))
+		result, err := p.parseDate(domain, strings.TrimSpace(match[1]))
+		if err != nil {
+			log.Debug().Err(err).Msgf("ignoring expiry date for %s", domain)
+		}
+		return result
+	}
+	return parse(registryExpiryRE), parse(registrarExpiryRE)
+}
+
+// pick returns the authoritative result between the registry and the
+// registrar ones, or false if neither has a date.
+func (policy ExpiryPolicy) pick(registry, registrar client.Result) (client.Result, bool) {
+	switch {
+	case registry.Expiry.IsZero() && registrar.Expiry.IsZero():
+		return client.Result{}, false
+	case registrar.Expiry.IsZero():
+		return registry, true
+	case registry.Expiry.IsZero():
+		return registrar, true
+	}
+
+	switch policy {
+	case PolicyRegistrar:
+		return registrar, true
+	case PolicyMin:
+		if registrar.Expiry.Before(registry.Expiry) {
+			return registrar, true
+		}
+		return registry, true
+	case PolicyMax:
+		if registrar.Expiry.After(registry.Expiry) {
+			return registrar, true
+		}
+		return registry, true
+	default:
+		return registry, true
+	}
+}
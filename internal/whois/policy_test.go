@@ -0,0 +1,71 @@
+package whois
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryPolicy(t *testing.T) {
+	registry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	registrar := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	body := `Domain Name: EXAMPLE.COM
+Registry Expiry Date: 2030-01-01T00:00:00Z
+Registrar Registration Expiration Date: 2031-01-01T00:00:00Z
+`
+	for _, tt := range []struct {
+		policy ExpiryPolicy
+		body   string
+		expect time.Time
+	}{
+		{policy: PolicyRegistry, body: body, expect: registry},
+		{policy: PolicyRegistrar, body: body, expect: registrar},
+		{policy: PolicyMin, body: body, expect: registry},
+		{policy: PolicyMax, body: body, expect: registrar},
+		{
+			policy: PolicyRegistrar,
+			body:   "Registry Expiry Date: 2030-01-01T00:00:00Z\nRegistrar Registration Expiration Date:\nRegistrar: Foo",
+			expect: registry,
+		},
+		{
+			policy: PolicyRegistry,
+			body:   "Registrar Registration Expiration Date: 2031-01-01T00:00:00Z",
+			expect: registrar,
+		},
+	} {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			fetcher, _ := fakeServers(t, map[string]string{"whois.a": tt.body})
+			cli := NewClient(WithExpiryPolicy(tt.policy)).(whoisClient)
+			cli.fetcher = fetcher
+
+			result, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, result.Expiry)
+		})
+	}
+
+	t.Run("sources", func(t *testing.T) {
+		fetcher, _ := fakeServers(t, map[string]string{"whois.a": body})
+		cli := NewClient().(whoisClient)
+		cli.fetcher = fetcher
+
+		result, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+		require.NoError(t, err)
+		require.Equal(t, registry, result.RegistryExpiry)
+		require.Equal(t, registrar, result.RegistrarExpiry)
+	})
+
+	t.Run("no sources", func(t *testing.T) {
+		fetcher, _ := fakeServers(t, map[string]string{"whois.a": "paid-till: 2030-01-01T00:00:00Z"})
+		cli := NewClient().(whoisClient)
+		cli.fetcher = fetcher
+
+		result, err := cli.Lookup(context.Background(), "example.ru", "whois.a")
+		require.NoError(t, err)
+		require.Equal(t, registry, result.Expiry)
+		require.True(t, result.RegistryExpiry.IsZero())
+		require.True(t, result.RegistrarExpiry.IsZero())
+	})
+}
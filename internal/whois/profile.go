@@ -0,0 +1,261 @@
+package whois
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile describes how to parse the whois response of a given TLD or
+// whois server.
+type Profile struct {
+	// ExpiryKeys are regular expressions matching the key that precedes
+	// the expiry date, e.g. "paid-till".
+	ExpiryKeys []string `yaml:"expiry_keys,omitempty"`
+	// Formats are the time layouts used to parse the expiry date.
+	Formats []string `yaml:"formats,omitempty"`
+	// Timezone is the IANA timezone used for dates without offset. If
+	// empty, the built-in hint for the TLD is used.
+	Timezone string `yaml:"timezone,omitempty"`
+	// ActiveStatus is a regular expression that, when matched and no
+	// expiry date is found, marks the domain as active.
+	ActiveStatus string `yaml:"active_status,omitempty"`
+	// ActiveValidity is how long an active domain is assumed to be valid.
+	ActiveValidity time.Duration `yaml:"active_validity,omitempty"`
+}
+
+// Profiles holds the default profile and the profiles keyed by TLD and
+// whois server.
+type Profiles struct {
+	Default Profile            `yaml:"default"`
+	TLDs    map[string]Profile `yaml:"tlds,omitempty"`
+	Hosts   map[string]Profile `yaml:"hosts,omitempty"`
+}
+
+// DefaultProfiles returns the built-in profiles.
+func DefaultProfiles() Profiles {
+	return Profiles{
+		Default: Profile{
+			ExpiryKeys: append([]string{}, expiryKeys...),
+			Formats:    append([]string{}, formats...),
+		},
+		TLDs: map[string]Profile{
+			// KZ whois doesn't provide explicit expiry dates.
+			"kz": {
+				ActiveStatus:   `Domain status\s*:\s*ok`,
+				ActiveValidity: 365 * 24 * time.Hour,
+			},
+		},
+		Hosts: map[string]Profile{},
+	}
+}
+
+// LoadProfiles reads profiles from the given YAML file and merges them
+// on top of the built-in ones.
+func LoadProfiles(pathToFile string) (Profiles, error) {
+	profiles := DefaultProfiles()
+	if pathToFile == "" {
+		return profiles, nil
+	}
+
+	filename, err := filepath.Abs(pathToFile)
+	if err != nil {
+		return profiles, fmt.Errorf("failed to get absolute path of file %s: %w", pathToFile, err)
+	}
+	log.Info().Msgf("loading whois profiles from file %s", filename)
+
+	yamlFile, err := os.ReadFile(filename)
+	if err != nil {
+		return profiles, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var custom Profiles
+	if err := yaml.Unmarshal(yamlFile, &custom); err != nil {
+		return profiles, fmt.Errorf("failed to unmarshal file: %w", err)
+	}
+
+	profiles.merge(custom)
+	if _, err := profiles.compile(); err != nil {
+		return profiles, err
+	}
+	return profiles, nil
+}
+
+func (p *Profiles) merge(custom Profiles) {
+	if len(custom.Default.ExpiryKeys) > 0 {
+		p.Default.ExpiryKeys = custom.Default.ExpiryKeys
+	}
+	if len(custom.Default.Formats) > 0 {
+		p.Default.Formats = custom.Default.Formats
+	}
+	if custom.Default.Timezone != "" {
+		p.Default.Timezone = custom.Default.Timezone
+	}
+	for tld, profile := range custom.TLDs {
+		p.TLDs[tld] = profile
+	}
+	for host, profile := range custom.Hosts {
+		p.Hosts[host] = profile
+	}
+}
+
+// parser is a compiled profile.
+type parser struct {
+	expiryREs      []*regexp.Regexp
+	formats        []string
+	location       *time.Location
+	activeRE       *regexp.Regexp
+	activeValidity time.Duration
+	// trace records the keys and layouts used, if debugging.
+	trace *client.Trace
+}
+
+type profileSet struct {
+	def   parser
+	tlds  map[string]parser
+	hosts map[string]parser
+}
+
+func (p Profiles) compile() (profileSet, error) {
+	def, err := compileProfile(p.Default, parser{})
+	if err != nil {
+		return profileSet{}, fmt.Errorf("invalid default profile: %w", err)
+	}
+
+	set := profileSet{
+		def:   def,
+		tlds:  map[string]parser{},
+		hosts: map[string]parser{},
+	}
+	for tld, profile := range p.TLDs {
+		key, err := idna.ToASCII(strings.Trim(strings.ToLower(tld), "."))
+		if err != nil {
+			return set, fmt.Errorf("invalid tld %q: %w", tld, err)
+		}
+		if set.tlds[key], err = compileProfile(profile, def); err != nil {
+			return set, fmt.Errorf("invalid profile for tld %q: %w", tld, err)
+		}
+	}
+	for host, profile := range p.Hosts {
+		if set.hosts[strings.ToLower(host)], err = compileProfile(profile, def); err != nil {
+			return set, fmt.Errorf("invalid profile for host %q: %w", host, err)
+		}
+	}
+	return set, nil
+}
+
+// compileProfile compiles the given profile, falling back to parent for
+// everything the profile does not define.
+func compileProfile(profile Profile, parent parser) (parser, error) {
+	result := parser{
+		expiryREs:      parent.expiryREs,
+		formats:        append(append([]string{}, profile.Formats...), parent.formats...),
+		location:       parent.location,
+		activeValidity: profile.ActiveValidity,
+	}
+
+	if len(profile.ExpiryKeys) > 0 {
+		re, err := regexp.Compile(`(?i)(` + strings.Join(profile.ExpiryKeys, "|") + `)\]?:?\s?(.*)`)
+		if err != nil {
+			return result, fmt.Errorf("invalid expiry keys: %w", err)
+		}
+		result.expiryREs = append([]*regexp.Regexp{re}, parent.expiryREs...)
+	}
+
+	if profile.Timezone != "" {
+		location, err := time.LoadLocation(profile.Timezone)
+		if err != nil {
+			return result, fmt.Errorf("invalid timezone: %w", err)
+		}
+		result.location = location
+	}
+
+	if profile.ActiveStatus != "" {
+		re, err := regexp.Compile(profile.ActiveStatus)
+		if err != nil {
+			return result, fmt.Errorf("invalid active status: %w", err)
+		}
+		result.activeRE = re
+	}
+
+	return result, nil
+}
+
+// lookup returns the parser for the given whois host or, if there is
+// none, for the longest matching TLD of the domain.
+func (s profileSet) lookup(domain, host string) parser {
+	if p, ok := s.hosts[strings.ToLower(host)]; ok {
+		return p
+	}
+	labels := strings.Split(domain, ".")
+	for i := 1; i < len(labels); i++ {
+		if p, ok := s.tlds[strings.Join(labels[i:], ".")]; ok {
+			return p
+		}
+	}
+	return s.def
+}
+
+// activeConfidence is the confidence of expiry dates assumed from the
+// domain status.
+const activeConfidence = 0.5
+
+func (p parser) expireTime(domain, body string) (client.Result, error) {
+	var err error
+	for _, re := range p.expiryREs {
+		result := re.FindStringSubmatch(body)
+		if len(result) < 3 {
+			continue
+		}
+		p.trace.Add("whois", "expiry key", result[1])
+		var parsed client.Result
+		if parsed, err = p.parseDate(domain, strings.TrimSpace(result[2])); err == nil {
+			return parsed, nil
+		}
+	}
+	if err != nil {
+		return client.Result{}, err
+	}
+
+	if p.activeRE != nil && p.activeRE.MatchString(body) {
+		log.Debug().Msg("domain is active based on status")
+		p.trace.Add("whois", "active status", p.activeRE.String())
+		return client.Result{
+			Expiry:     time.Now().Add(p.activeValidity).UTC(),
+			Confidence: activeConfidence,
+		}, nil
+	}
+	return client.Result{}, fmt.Errorf("%w: could not parse whois response: %q", client.ErrNoExpiry, body)
+}
+
+// parseDate parses the given date using the profile layouts, falling back
+// to heuristics.
+func (p parser) parseDate(domain, value string) (client.Result, error) {
+	location := p.location
+	if location == nil {
+		location = dateparse.Location(domain)
+	}
+
+	date, layout, err := dateparse.Parse(value, p.formats, location)
+	if err == nil {
+		p.trace.Add("whois", "layout", fmt.Sprintf("%q parsed %q as %s", layout, value, date))
+		return client.Result{Expiry: date, Confidence: dateparse.ConfidenceExact}, nil
+	}
+	date, confidence, ferr := dateparse.Fuzzy(value, location, dateparse.DayFirst(domain))
+	if ferr != nil {
+		p.trace.Add("whois", "layout", fmt.Sprintf("none parsed %q", value))
+		return client.Result{}, fmt.Errorf("%w: %w", client.ErrBadDate, err)
+	}
+	log.Debug().Msgf("parsed %q as %s with confidence %.2f", value, date, confidence)
+	p.trace.Add("whois", "layout", fmt.Sprintf("heuristic parsed %q as %s with confidence %.2f", value, date, confidence))
+	return client.Result{Expiry: date, Confidence: confidence}, nil
+}
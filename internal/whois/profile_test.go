@@ -0,0 +1,170 @@
+package whois
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	t.Run("empty path", func(t *testing.T) {
+		profiles, err := LoadProfiles("")
+		require.NoError(t, err)
+		require.Equal(t, DefaultProfiles(), profiles)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadProfiles("file-which-does-not-exist.yaml")
+		require.Error(t, err)
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := LoadProfiles(writeProfiles(t, `
+tlds:
+  foo:
+    expiry_keys: ["("]
+`))
+		require.ErrorContains(t, err, `invalid profile for tld "foo"`)
+	})
+
+	t.Run("invalid timezone", func(t *testing.T) {
+		_, err := LoadProfiles(writeProfiles(t, `
+hosts:
+  whois.foo:
+    timezone: Nowhere/Nope
+`))
+		require.ErrorContains(t, err, `invalid profile for host "whois.foo"`)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		profiles, err := LoadProfiles(writeProfiles(t, `
+tlds:
+  kz:
+    expiry_keys: ["valid thru"]
+  foo:
+    formats: ["2006|01|02"]
+hosts:
+  whois.foo:
+    timezone: America/Sao_Paulo
+`))
+		require.NoError(t, err)
+		require.Equal(t, DefaultProfiles().Default, profiles.Default)
+		require.Equal(t, []string{"valid thru"}, profiles.TLDs["kz"].ExpiryKeys)
+		require.Empty(t, profiles.TLDs["kz"].ActiveStatus)
+		require.Equal(t, []string{"2006|01|02"}, profiles.TLDs["foo"].Formats)
+		require.Equal(t, "America/Sao_Paulo", profiles.Hosts["whois.foo"].Timezone)
+	})
+}
+
+func TestProfileParsing(t *testing.T) {
+	profiles := DefaultProfiles()
+	profiles.TLDs["foo"] = Profile{
+		ExpiryKeys: []string{"valid thru"},
+		Formats:    []string{"2006|01|02"},
+	}
+	profiles.TLDs["bar.foo"] = Profile{Timezone: "Asia/Tokyo"}
+	profiles.Hosts["whois.foo"] = Profile{Formats: []string{"02 01 2006"}}
+	set, err := profiles.compile()
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name       string
+		domain     string
+		host       string
+		body       string
+		expect     time.Time
+		confidence float64
+		err        string
+	}{
+		{
+			name:       "default profile",
+			domain:     "example.com",
+			body:       "Registry Expiry Date: 2030-01-02T03:04:05Z",
+			confidence: 1,
+			expect:     time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name:       "tld profile key and format",
+			domain:     "example.foo",
+			body:       "valid thru: 2030|01|02",
+			confidence: 1,
+			expect:     time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "tld profile falls back to default keys",
+			domain:     "example.foo",
+			body:       "paid-till: 2030-01-02",
+			confidence: 1,
+			expect:     time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "longest tld wins",
+			domain:     "example.bar.foo",
+			body:       "Expiry date: 2030-01-02 09:00:00",
+			confidence: 1,
+			expect:     time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "host profile wins over tld",
+			domain:     "example.foo",
+			host:       "WHOIS.FOO",
+			body:       "Expiry date: 02 01 2030",
+			confidence: 1,
+			expect:     time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "heuristic date",
+			domain:     "example.foo",
+			body:       "valid thru: 2nd of January 2030",
+			confidence: dateparse.ConfidenceMonthName,
+			expect:     time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "bad date",
+			domain: "example.foo",
+			body:   "valid thru: soon",
+			err:    `could not parse date: "soon"`,
+		},
+		{
+			name:   "no key",
+			domain: "example.com",
+			body:   "nothing to see here",
+			err:    "could not parse whois response",
+		},
+		{
+			name:   "kz inactive",
+			domain: "example.kz",
+			body:   "Domain status : clientHold",
+			err:    "could not parse whois response",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := set.lookup(tt.domain, tt.host).expireTime(tt.domain, tt.body)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, result.Expiry)
+			require.InDelta(t, tt.confidence, result.Confidence, 0.0001)
+		})
+	}
+
+	t.Run("kz active", func(t *testing.T) {
+		result, err := set.lookup("example.kz", "whois.nic.kz").expireTime("example.kz", "Domain status : ok - Normal state")
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().AddDate(1, 0, 0), result.Expiry, 2*24*time.Hour)
+		require.Less(t, result.Confidence, 1.0)
+	})
+}
+
+func writeProfiles(tb testing.TB, content string) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "profiles.yaml")
+	require.NoError(tb, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
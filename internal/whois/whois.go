@@ -3,6 +3,7 @@ package whois
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -45,7 +46,7 @@ var (
 		"2006/01/02",                  // .ca, .jp
 		"2006-01-02 (YYYY-MM-DD)",     // .tw
 		"(dd/mm/yyyy): 02/01/2006",    // .pt
-		"02-Jan-2006 15:04:05 UTC",    // .id, .co.id
+		"02-Jan-2006 15:04:05 MST",    // .id, .co.id
 		": 2006. 01. 02.",             // .kr
 		"2006-01-02 15:04:05 (UTC+8)", // .tw
 		"02/01/2006 15:04:05",         // .im
@@ -54,16 +55,14 @@ var (
 		"2.1.2006 15:04:05",           // .fi
 		"02-01-2006",                  // .hk
 		"2006-Jan-02.",                // .com.tr
-		"2006-01-02 15:04:05 (GMT+0)", // .kz
+		"2006-01-02 15:04:05 (MST)",   // .kz
 		"2006-01-02T15:04:05Z",        // .ph
 		"2006.01.02",                  // .ru
-		"2006-01-02 15:04:05 CLST",    // .cl
-		"2006-01-02 15:04:05 CLT",     // .cl
+		"2006-01-02 15:04:05 MST",     // .cl
 		"2006-01-02 15:04:05",         // .hk
 	}
 
-	// nolint: lll
-	expiryRE = regexp.MustCompile(`(?i)(` + strings.Join([]string{
+	expiryKeys = []string{
 		"Registrar Registration Expiration Date",
 		"expire-date",
 		"Valid Until",
@@ -87,84 +86,198 @@ var (
 		"OK-UNTIL",
 		"registered",
 		`Registered:\t\t`,
-	}, "|") + `)\]?:?\s?(.*)`)
+	}
 	registrarRE = regexp.MustCompile(`(?i)Registrar WHOIS Server: (.*)`)
+	rateLimitRE = regexp.MustCompile(`(?im)^[ \t>%#*-]*(too many (requests|queries)|(query |request )?rate limit exceeded|(whois |query |connection )?limit exceeded|you have exceeded).*$`)
+	notFoundRE  = regexp.MustCompile(`(?im)^[ \t>%#]*(No match|NOT FOUND|No entries found|No Data Found|No Object Found|Domain not found|Status:[ \t]*(free|AVAILABLE)|The queried object does not exist).*$`)
+	referralRE  = regexp.MustCompile(`(?im)^\s*(?:Registrar WHOIS Server|refer|whois):[ \t]*(\S+)`)
 )
 
-type whoisClient struct{}
+type whoisClient struct {
+	profiles     profileSet
+	fetcher      *whois.Client
+	referral     bool
+	maxReferrals int
+	policy       ExpiryPolicy
+}
+
+// Option configures the whois client.
+type Option func(*whoisClient)
+
+// WithProfiles sets the parsing profiles used by the client.
+func WithProfiles(profiles Profiles) Option {
+	return func(c *whoisClient) {
+		set, err := profiles.compile()
+		if err != nil {
+			log.Error().Err(err).Msg("invalid whois profiles, using built-in ones")
+			return
+		}
+		c.profiles = set
+	}
+}
+
+// WithReferral makes the client follow referrals to other whois servers,
+// such as "Registrar WHOIS Server", "refer" and "whois" lines, up to
+// maxReferrals hops.
+func WithReferral(maxReferrals int) Option {
+	return func(c *whoisClient) {
+		c.referral = true
+		c.maxReferrals = maxReferrals
+	}
+}
 
 // NewClient return a "live" whois client.
-func NewClient() client.Client {
-	return whoisClient{}
+func NewClient(opts ...Option) client.Client {
+	set, err := DefaultProfiles().compile()
+	if err != nil {
+		panic(err)
+	}
+	c := whoisClient{profiles: set, fetcher: whois.DefaultClient, policy: PolicyRegistry}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
 }
 
-func (c whoisClient) ExpireTime(ctx context.Context, domain string, host string) (time.Time, error) {
+func (c whoisClient) Lookup(ctx context.Context, domain string, host string) (client.Result, error) {
 	log.Debug().Msgf("trying whois client for %q", domain)
-	body, err := c.request(ctx, domain, host)
+	normalizedDomain, err := idna.ToASCII(strings.ToLower(domain))
 	if err != nil {
-		return time.Now(), err
+		return client.Result{}, fmt.Errorf("failed to normalize domain name: %w", err)
 	}
-	result := expiryRE.FindStringSubmatch(body)
-	if len(result) < 2 {
-		return time.Now(), fmt.Errorf("could not parse whois response: %q", body)
+	body, foundHost, err := c.request(ctx, normalizedDomain, host)
+	if err != nil {
+		return client.Result{}, err
 	}
-	dateStr := strings.TrimSpace(result[2])
-	for _, format := range formats {
-		if date, err := time.Parse(format, dateStr); err == nil {
-			log.Debug().Msgf("domain %q will expire at %q", domain, date.String())
-			return date, nil
+	parser := c.profiles.lookup(normalizedDomain, foundHost)
+	parser.trace = client.TraceFrom(ctx)
+	registry, registrar := parser.sourceExpiry(normalizedDomain, body)
+	result, ok := c.policy.pick(registry, registrar)
+	if !ok {
+		if result, err = parser.expireTime(normalizedDomain, body); err != nil {
+			// only a response without a parsable expiry is a missing domain
+			// or a refusal, as some registries mention "not found" or rate
+			// limits in valid responses too.
+			if notFound := notFoundRE.FindString(body); notFound != "" {
+				return client.Result{}, fmt.Errorf("%w: %s", client.ErrDomainNotFound, strings.TrimSpace(notFound))
+			}
+			if limited := rateLimitRE.FindString(body); limited != "" {
+				return client.Result{}, fmt.Errorf("%w: %s", client.ErrRateLimited, strings.TrimSpace(limited))
+			}
+			return client.Result{}, err
 		}
 	}
-	return time.Now(), fmt.Errorf("could not parse date: %q", dateStr)
+	result.RegistryExpiry = registry.Expiry
+	result.RegistrarExpiry = registrar.Expiry
+	result.WhoisServer = foundHost
+	log.Debug().Msgf("domain %q will expire at %q", domain, result.Expiry.String())
+	return result, nil
 }
 
-func (c whoisClient) request(ctx context.Context, domain, host string) (string, error) {
-	normalizedDomain := strings.ToLower(domain)
-
-	normalizedDomain, err := idna.ToASCII(normalizedDomain)
-	if err != nil {
-		return "", fmt.Errorf("failed to normalize domain name: %w", err)
-	}
-
+// fetch does a single whois request, returning the response text and the
+// host that answered.
+func (c whoisClient) fetch(ctx context.Context, normalizedDomain, host string) (string, string, error) {
 	req := &whois.Request{
 		Query: normalizedDomain,
 		Host:  host,
 	}
 	if err := req.Prepare(); err != nil {
-		return "", fmt.Errorf("failed to prepare: %w", err)
+		return "", "", fmt.Errorf("failed to prepare: %w", err)
 	}
-	resp, err := whois.DefaultClient.FetchContext(ctx, req)
+	trace := client.TraceFrom(ctx)
+	trace.Add("whois", "request", req.Host)
+	resp, err := c.fetcher.FetchContext(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch whois request: %w", err)
+		return "", "", client.Classify(fmt.Errorf("failed to fetch whois request: %w", err))
 	}
 	respText, err := resp.Text()
 	if err != nil {
-		return "", fmt.Errorf("failed to parse response body into text: %w", err)
+		return "", "", fmt.Errorf("failed to parse response body into text: %w", err)
 	}
+	trace.Add("whois", "response", string(respText))
+	return string(respText), req.Host, nil
+}
 
-	body := string(respText)
+// follow fetches the whois response for the given normalized domain and
+// follows referrals until there are none left, a server was already
+// visited, or maxReferrals is reached.
+func (c whoisClient) follow(ctx context.Context, normalizedDomain, host string) (string, string, error) {
+	body, host, err := c.fetch(ctx, normalizedDomain, host)
+	if err != nil {
+		return "", "", err
+	}
+
+	visited := map[string]bool{strings.ToLower(host): true}
+	for range c.maxReferrals {
+		next := referral(body)
+		if next == "" {
+			break
+		}
+		if visited[next] {
+			log.Debug().Msgf("whois referral loop to %s for %s", next, normalizedDomain)
+			break
+		}
+		visited[next] = true
+
+		log.Debug().Msgf("following whois referral from %s to %s for %s", host, next, normalizedDomain)
+		client.TraceFrom(ctx).Add("whois", "referral", host+" -> "+next)
+		nextBody, nextHost, err := c.fetch(ctx, normalizedDomain, next)
+		if err != nil {
+			log.Debug().Err(err).Msgf("ignoring error from %s for %s", next, normalizedDomain)
+			break
+		}
+		body, host = nextBody, nextHost
+	}
+	return body, host, nil
+}
+
+// referral returns the whois server the response refers to, if any.
+func referral(body string) string {
+	result := referralRE.FindStringSubmatch(body)
+	if len(result) < 2 {
+		return ""
+	}
+	host := strings.ToLower(result[1])
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+// request fetches the whois response for the given normalized domain,
+// returning the response text and the host that answered.
+func (c whoisClient) request(ctx context.Context, normalizedDomain, host string) (string, string, error) {
+	if c.referral {
+		return c.follow(ctx, normalizedDomain, host)
+	}
+
+	body, answered, err := c.fetch(ctx, normalizedDomain, host)
+	if err != nil {
+		return "", "", err
+	}
 
 	if host == "" {
 		// do not recurse
-		return body, nil
+		return body, answered, nil
 	}
 
 	result := registrarRE.FindStringSubmatch(body)
 	if len(result) < 2 {
-		log.Debug().Msgf("couldn't find registrar url in whois response: %s", domain)
-		return body, nil
+		log.Debug().Msgf("couldn't find registrar url in whois response: %s", normalizedDomain)
+		return body, answered, nil
 	}
 
 	foundHost := strings.TrimSpace(result[1])
 	if foundHost == host || foundHost == "" {
-		return body, nil
+		return body, answered, nil
 	}
 
-	log.Debug().Msgf("found whois host %s for domain %s", foundHost, domain)
-	if newBody, err := c.request(ctx, domain, foundHost); err == nil {
-		return newBody, err
+	log.Debug().Msgf("found whois host %s for domain %s", foundHost, normalizedDomain)
+	client.TraceFrom(ctx).Add("whois", "referral", answered+" -> "+foundHost)
+	if newBody, newHost, err := c.request(ctx, normalizedDomain, foundHost); err == nil {
+		return newBody, newHost, nil
 	}
 
-	log.Debug().Msgf("ignoring error from %s for %s", foundHost, domain)
-	return body, nil
+	log.Debug().Msgf("ignoring error from %s for %s", foundHost, normalizedDomain)
+	return body, answered, nil
 }
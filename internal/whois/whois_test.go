@@ -1,11 +1,18 @@
 package whois
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/dateparse"
+	"github.com/domainr/whois"
 	"github.com/stretchr/testify/require"
 )
 
@@ -66,7 +73,7 @@ func TestWhoisParsing(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			t.Cleanup(cancel)
 
-			expiry, err := NewClient().ExpireTime(ctx, tt.domain, tt.host)
+			result, err := NewClient().Lookup(ctx, tt.domain, tt.host)
 			if err != nil {
 				errs := err.Error()
 				if strings.Contains(errs, "i/o timeout") {
@@ -79,9 +86,9 @@ func TestWhoisParsing(t *testing.T) {
 			if tt.err == "" {
 				require.NoError(t, err)
 				if tt.expired {
-					require.Greater(t, time.Since(expiry).Hours(), 0.0)
+					require.Greater(t, time.Since(result.Expiry).Hours(), 0.0)
 				} else {
-					require.Less(t, time.Since(expiry).Hours(), 0.0)
+					require.Less(t, time.Since(result.Expiry).Hours(), 0.0)
 				}
 			} else {
 				require.ErrorContains(t, err, tt.err)
@@ -90,3 +97,290 @@ func TestWhoisParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestFormats(t *testing.T) {
+	for _, tt := range []struct {
+		domain string
+		value  string
+		expect string
+	}{
+		{domain: "google.com", value: "Mon Jan  2 15:04:05 2030", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com", value: "Wed Jan  2 15:04:05 EST 2030", expect: "2030-01-02T20:04:05Z"},
+		{domain: "google.com", value: "Wed Jan 02 15:04:05 -0300 2030", expect: "2030-01-02T18:04:05Z"},
+		{domain: "google.com", value: "02 Jan 30 15:04 CET", expect: "2030-01-02T14:04:00Z"},
+		{domain: "google.com", value: "02 Jan 30 15:04 +0800", expect: "2030-01-02T07:04:00Z"},
+		{domain: "google.com", value: "Wednesday, 02-Jan-30 15:04:05 UTC", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com", value: "Wed, 02 Jan 2030 15:04:05 GMT", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com", value: "Wed, 02 Jan 2030 15:04:05 +0100", expect: "2030-01-02T14:04:05Z"},
+		{domain: "google.com", value: "2030-01-02T15:04:05Z", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.com", value: "2030-01-02T15:04:05.123456Z", expect: "2030-01-02T15:04:05.123456Z"},
+		{domain: "google.com", value: "2030-01-02T15:04:05-0700", expect: "2030-01-02T22:04:05Z"},
+		{domain: "google.com.br", value: "20300102", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.ua", value: "20300102150405", expect: "2030-01-02T13:04:05Z"},
+		{domain: "google.lt", value: "2030-01-02", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.ua", value: "2030-01-02 15:04:05+03", expect: "2030-01-02T12:04:05Z"},
+		{domain: "google.ch", value: "2030-01-02 15:04:05", expect: "2030-01-02T14:04:05Z"},
+		{domain: "google.host", value: "2030-01-02T15:04:05.0Z", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.is", value: "January  2 2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.cz", value: "02.01.2030", expect: "2030-01-01T23:00:00Z"},
+		{domain: "google.fr", value: "02/01/2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.ie", value: "02-January-2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.pl", value: "2030.01.02 15:04:05", expect: "2030-01-02T14:04:05Z"},
+		{domain: "bbc.co.uk", value: "02-Jan-2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.sg", value: "02-Jan-2030 15:04:05", expect: "2030-01-02T07:04:05Z"},
+		{domain: "google.ca", value: "2030/01/02", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.jp", value: "2030/01/02", expect: "2030-01-01T15:00:00Z"},
+		{domain: "google.com.tw", value: "2030-01-02 (YYYY-MM-DD)", expect: "2030-01-01T16:00:00Z"},
+		{domain: "google.pt", value: "(dd/mm/yyyy): 02/01/2030", expect: "2030-01-02T00:00:00Z"},
+		{domain: "google.co.id", value: "02-Jan-2030 15:04:05 UTC", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.kr", value: ": 2030. 01. 02.", expect: "2030-01-01T15:00:00Z"},
+		{domain: "google.com.tw", value: "2030-01-02 15:04:05 (UTC+8)", expect: "2030-01-02T07:04:05Z"},
+		{domain: "microsoft.im", value: "02/01/2030 15:04:05", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.rs", value: "02.01.2030 15:04:05", expect: "2030-01-02T14:04:05Z"},
+		{domain: "google.co.th", value: "02 Jan 2030", expect: "2030-01-01T17:00:00Z"},
+		{domain: "google.fi", value: "2.1.2030 15:04:05", expect: "2030-01-02T13:04:05Z"},
+		{domain: "google.com.hk", value: "02-01-2030", expect: "2030-01-01T16:00:00Z"},
+		{domain: "google.com.tr", value: "2030-Jan-02.", expect: "2030-01-01T21:00:00Z"},
+		{domain: "nic.kz", value: "2030-01-02 15:04:05 (GMT+0)", expect: "2030-01-02T15:04:05Z"},
+		{domain: "google.ru", value: "2030.01.02", expect: "2030-01-01T21:00:00Z"},
+		{domain: "google.cl", value: "2030-01-02 15:04:05 CLST", expect: "2030-01-02T18:04:05Z"},
+		{domain: "google.cl", value: "2030-07-02 15:04:05 CLT", expect: "2030-07-02T19:04:05Z"},
+		{domain: "google.com.hk", value: "2030-01-02 15:04:05", expect: "2030-01-02T07:04:05Z"},
+	} {
+		t.Run(tt.domain+" "+tt.value, func(t *testing.T) {
+			expect, err := time.Parse(time.RFC3339Nano, tt.expect)
+			require.NoError(t, err)
+			date, _, err := dateparse.Parse(tt.value, formats, dateparse.Location(tt.domain))
+			require.NoError(t, err)
+			require.Equal(t, expect, date)
+		})
+	}
+}
+
+// fakeServers returns a whois client which answers requests with the
+// given responses by host, recording which hosts were queried.
+func fakeServers(tb testing.TB, responses map[string]string) (*whois.Client, *[]string) {
+	tb.Helper()
+	var mu sync.Mutex
+	var queried []string
+	return &whois.Client{
+		DialContext: func(_ context.Context, _, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			require.NoError(tb, err)
+			mu.Lock()
+			queried = append(queried, host)
+			mu.Unlock()
+
+			response, ok := responses[host]
+			if !ok {
+				return nil, fmt.Errorf("dial tcp: lookup %s: no such host", host)
+			}
+			conn, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				_, _ = bufio.NewReader(server).ReadString('\n')
+				_, _ = server.Write([]byte(response))
+			}()
+			return conn, nil
+		},
+	}, &queried
+}
+
+func TestReferral(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		opts      []Option
+		responses map[string]string
+		server    string
+		queried   []string
+		err       string
+	}{
+		{
+			name: "referrals disabled",
+			responses: map[string]string{
+				"whois.a": "refer: whois.b\nExpiry date: 2030-01-01",
+			},
+			server:  "whois.a",
+			queried: []string{"whois.a"},
+		},
+		{
+			name: "chain",
+			opts: []Option{WithReferral(3)},
+			responses: map[string]string{
+				"whois.a": "refer:        whois.b\n",
+				"whois.b": "Domain Name: example.com\n   Registrar WHOIS Server: whois.c\n",
+				"whois.c": "whois: WHOIS://whois.d/\n",
+				"whois.d": "Expiry date: 2030-01-01\n",
+			},
+			server:  "whois.d",
+			queried: []string{"whois.a", "whois.b", "whois.c", "whois.d"},
+		},
+		{
+			name: "loop",
+			opts: []Option{WithReferral(10)},
+			responses: map[string]string{
+				"whois.a": "refer: whois.b\n",
+				"whois.b": "refer: whois.a\nExpiry date: 2030-01-01",
+			},
+			server:  "whois.b",
+			queried: []string{"whois.a", "whois.b"},
+		},
+		{
+			name: "max referrals",
+			opts: []Option{WithReferral(1)},
+			responses: map[string]string{
+				"whois.a": "refer: whois.b\n",
+				"whois.b": "refer: whois.c\nExpiry date: 2030-01-01",
+				"whois.c": "Expiry date: 2040-01-01",
+			},
+			server:  "whois.b",
+			queried: []string{"whois.a", "whois.b"},
+		},
+		{
+			name: "referral fails",
+			opts: []Option{WithReferral(3)},
+			responses: map[string]string{
+				"whois.a": "refer: whois.b\nExpiry date: 2030-01-01",
+			},
+			server:  "whois.a",
+			queried: []string{"whois.a", "whois.b"},
+		},
+		{
+			name:      "first server fails",
+			opts:      []Option{WithReferral(3)},
+			responses: map[string]string{},
+			queried:   []string{"whois.a"},
+			err:       "no such host",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher, queried := fakeServers(t, tt.responses)
+			cli := NewClient(tt.opts...).(whoisClient)
+			cli.fetcher = fetcher
+
+			result, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+			require.Equal(t, tt.queried, *queried)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.server, result.WhoisServer)
+			require.Equal(t, 2030, result.Expiry.Year())
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	for _, body := range []string{
+		"No match for \"FAKEDOMAIN.COM\".\n>>> Last update of whois database: 2030-01-01T00:00:00Z <<<",
+		"NOT FOUND\n",
+		"Domain: fakedomain.de\nStatus: free\n",
+		"%% No entries found for the selected source(s).\n",
+		"Registrar WHOIS Server: whois.dot.ph\nDomain not found or parsing error",
+	} {
+		t.Run(body, func(t *testing.T) {
+			fetcher, _ := fakeServers(t, map[string]string{"whois.a": body})
+			cli := NewClient().(whoisClient)
+			cli.fetcher = fetcher
+
+			_, err := cli.Lookup(context.Background(), "fakedomain.com", "whois.a")
+			require.ErrorIs(t, err, client.ErrDomainNotFound)
+		})
+	}
+
+	t.Run("registered", func(t *testing.T) {
+		fetcher, _ := fakeServers(t, map[string]string{
+			"whois.a": "Registry Expiry Date: 2030-01-01T00:00:00Z\nDNSSEC: not found\n",
+		})
+		cli := NewClient().(whoisClient)
+		cli.fetcher = fetcher
+
+		_, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+		require.NoError(t, err)
+	})
+
+	t.Run("registered with not found line", func(t *testing.T) {
+		fetcher, _ := fakeServers(t, map[string]string{
+			"whois.a": "% No match for reserved names\nExpiry date: 2030-01-02\n",
+		})
+		cli := NewClient().(whoisClient)
+		cli.fetcher = fetcher
+
+		result, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+		require.NoError(t, err)
+		require.Equal(t, 2030, result.Expiry.Year())
+	})
+}
+
+func TestErrors(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		err  error
+	}{
+		{body: "%% Too many queries, please try again later\n", err: client.ErrRateLimited},
+		{body: "WHOIS LIMIT EXCEEDED - SEE WWW.PIR.ORG/WHOIS FOR DETAILS\n", err: client.ErrRateLimited},
+		{body: "Domain Name: example.com\n% Queries are subject to rate limits\n", err: client.ErrNoExpiry},
+		{body: "Domain Name: example.com\n", err: client.ErrNoExpiry},
+		{body: "Registry Expiry Date: soon\n", err: client.ErrBadDate},
+	} {
+		t.Run(tt.body, func(t *testing.T) {
+			fetcher, _ := fakeServers(t, map[string]string{"whois.a": tt.body})
+			cli := NewClient().(whoisClient)
+			cli.fetcher = fetcher
+
+			_, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestRateLimitMention(t *testing.T) {
+	fetcher, _ := fakeServers(t, map[string]string{
+		"whois.a": "Expiry date: 2030-01-02\n% Queries are subject to rate limits\n",
+	})
+	cli := NewClient().(whoisClient)
+	cli.fetcher = fetcher
+
+	result, err := cli.Lookup(context.Background(), "example.com", "whois.a")
+	require.NoError(t, err)
+	require.Equal(t, 2030, result.Expiry.Year())
+}
+
+func TestTrace(t *testing.T) {
+	fetcher, _ := fakeServers(t, map[string]string{
+		"whois.a": "refer: whois.b\n",
+		"whois.b": "Domain Name: example.com\nExpiry date: 2030-01-02\n",
+	})
+	cli := NewClient(WithReferral(3)).(whoisClient)
+	cli.fetcher = fetcher
+
+	trace := &client.Trace{}
+	_, err := cli.Lookup(client.WithTrace(context.Background(), trace), "example.com", "whois.a")
+	require.NoError(t, err)
+	require.Equal(t, []client.TraceEvent{
+		{Backend: "whois", Step: "request", Value: "whois.a"},
+		{Backend: "whois", Step: "response", Value: "refer: whois.b\n"},
+		{Backend: "whois", Step: "referral", Value: "whois.a -> whois.b"},
+		{Backend: "whois", Step: "request", Value: "whois.b"},
+		{Backend: "whois", Step: "response", Value: "Domain Name: example.com\nExpiry date: 2030-01-02\n"},
+		{Backend: "whois", Step: "expiry key", Value: "Expiry date"},
+		{Backend: "whois", Step: "layout", Value: `"2006-01-02" parsed "2030-01-02" as 2030-01-02 00:00:00 +0000 UTC`},
+	}, trace.Events())
+}
+
+func TestTraceSourceExpiry(t *testing.T) {
+	fetcher, _ := fakeServers(t, map[string]string{
+		"whois.a": "Registry Expiry Date: 2030-01-02T00:00:00Z\n",
+	})
+	cli := NewClient().(whoisClient)
+	cli.fetcher = fetcher
+
+	trace := &client.Trace{}
+	_, err := cli.Lookup(client.WithTrace(context.Background(), trace), "example.com", "whois.a")
+	require.NoError(t, err)
+	events := trace.Events()
+	require.Len(t, events, 4)
+	require.Equal(t, client.TraceEvent{Backend: "whois", Step: "expiry key", Value: "Registry Expiry Date:"}, events[2])
+	require.Equal(t, "layout", events[3].Step)
+}
@@ -4,16 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // registry timezones, as the docker image has no tzdata
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/caarlos0/domain_exporter/internal/api"
 	"github.com/caarlos0/domain_exporter/internal/client"
 	"github.com/caarlos0/domain_exporter/internal/collector"
+	"github.com/caarlos0/domain_exporter/internal/generate"
+	"github.com/caarlos0/domain_exporter/internal/history"
+	"github.com/caarlos0/domain_exporter/internal/notifier"
 	"github.com/caarlos0/domain_exporter/internal/rdap"
 	"github.com/caarlos0/domain_exporter/internal/refresher"
 	"github.com/caarlos0/domain_exporter/internal/safeconfig"
@@ -21,30 +28,43 @@ import (
 	cache "github.com/patrickmn/go-cache"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 // nolint: gochecknoglobals
 var (
-	bind       = kingpin.Flag("bind", "addr to bind the server").Short('b').Default(":9222").String()
-	debug      = kingpin.Flag("debug", "show debug logs").Default("false").Bool()
-	format     = kingpin.Flag("logFormat", "log format to use").Default("console").Enum("json", "console")
-	interval   = kingpin.Flag("cache", "time to cache the result of whois calls").Default("2h").Duration()
-	timeout    = kingpin.Flag("timeout", "timeout for each domain").Default("10s").Duration()
-	configFile = kingpin.Flag("config", "configuration file").String()
-	version    = "dev"
+	bind          = kingpin.Flag("bind", "addr to bind the server").Short('b').Default(":9222").String()
+	debug         = kingpin.Flag("debug", "show debug logs").Default("false").Bool()
+	format        = kingpin.Flag("logFormat", "log format to use").Default("console").Enum("json", "console")
+	interval      = kingpin.Flag("cache", "time to cache the result of whois calls").Default("2h").Duration()
+	timeout       = kingpin.Flag("timeout", "timeout for each domain").Default("10s").Duration()
+	timeoutOffset = kingpin.Flag("timeout-offset", "offset to subtract from the Prometheus scrape timeout").Default("0.5s").Duration()
+	configFile    = kingpin.Flag("config", "configuration file").String()
+	profiles      = kingpin.Flag("whois-profiles", "YAML file with custom whois parsing profiles").String()
+	referral      = kingpin.Flag("whois-referral", "follow whois referrals to other servers").Default("false").Bool()
+	referrals     = kingpin.Flag("whois-max-referrals", "maximum number of whois referrals to follow").Default("3").Int()
+	strategy      = kingpin.Flag("strategy", "how to use the rdap and whois backends").Default("sequential").Enum("sequential", "race", "consensus")
+	policy        = kingpin.Flag("expiry-policy", "which expiry to use when registry and registrar disagree").Default("registry").Enum("registry", "registrar", "min", "max")
+	snapshot      = kingpin.Flag("snapshot", "serve /metrics from the last results of the refresher, never probing on scrapes").Default("false").Bool()
+	legacyDays    = kingpin.Flag("legacy-expiry-days", "export domain_expiry_days as -1 on failed probes, as older versions did").Default("false").Bool()
+	historyFile   = kingpin.Flag("history-file", "file to keep the expiry history of the domains in across restarts").String()
+	apiToken      = kingpin.Flag("api-token", "token required by the admin endpoints of the API, which are disabled if empty").Envar("DOMAIN_EXPORTER_API_TOKEN").String()
+	version       = "dev"
+
+	serveCmd     = kingpin.Command("serve", "serve the metrics, the default").Default()
+	generateCmd  = kingpin.Command("generate", "generate configuration for other tools")
+	rulesCmd     = generateCmd.Command("rules", "print Prometheus alerting rules for the configured thresholds and groups")
+	rulesFormat  = rulesCmd.Flag("format", "format of the rules").Default("rules").Enum("rules", "prometheus-rule")
+	rulesLabels  = rulesCmd.Flag("label", "label to add to the rules, as key=value").StringMap()
+	dashboardCmd = generateCmd.Command("dashboard", "print a Grafana dashboard for the exported metrics")
 )
 
 func main() {
 	kingpin.Version("domain_exporter version " + version)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
-
-	urlPrefix, urlPrefixOK := os.LookupEnv("DOMAIN_EXPORTER_URL_PREFIX")
-	if !urlPrefixOK {
-		urlPrefix = ""
-	}
+	command := kingpin.Parse()
 
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	if *format == "console" {
@@ -55,12 +75,33 @@ func main() {
 		log.Debug().Msg("enabled debug mode")
 	}
 
+	switch command {
+	case rulesCmd.FullCommand():
+		generateRules()
+	case dashboardCmd.FullCommand():
+		generateDashboard()
+	case serveCmd.FullCommand():
+		serve()
+	}
+}
+
+func serve() {
+	urlPrefix, urlPrefixOK := os.LookupEnv("DOMAIN_EXPORTER_URL_PREFIX")
+	if !urlPrefixOK {
+		urlPrefix = ""
+	}
+
 	log.Info().Msgf("starting domain_exporter %s", version)
 	cfg, err := safeconfig.New(*configFile)
 	if err != nil {
 		log.Fatal().Err(err).Msg("error to create config")
 	}
 
+	whoisProfiles, err := whois.LoadProfiles(*profiles)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error to load whois profiles")
+	}
+
 	wg := &sync.WaitGroup{}
 	defer wg.Wait()
 
@@ -68,36 +109,55 @@ func main() {
 	defer cancel()
 
 	cache := cache.New(*interval, *interval)
-	cli := client.NewMultiClient(rdap.NewClient(), whois.NewClient())
-	cachedClient := client.NewCachedClient(cli, cache)
+	metrics := client.NewMetrics()
+	prometheus.DefaultRegisterer.MustRegister(metrics)
+	defaults := safeconfig.Module{
+		Protocols:    []string{"rdap", "whois"},
+		Timeout:      *timeout,
+		Strategy:     *strategy,
+		ExpiryPolicy: *policy,
+		Referral:     referral,
+		MaxReferrals: *referrals,
+	}
+	cachedClient, probers := newProbers(cache, defaults, cfg.Modules, func(module safeconfig.Module) (client.Client, string) {
+		return newClient(module, metrics, whoisProfiles)
+	})
+	prometheus.DefaultRegisterer.MustRegister(cachedClient)
 
+	hist, err := history.New(*historyFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error to load history")
+	}
+	prometheus.DefaultRegisterer.MustRegister(hist)
+
+	fresh := refresher.New(*interval, history.NewClient(cachedClient, hist), *timeout*time.Duration(max(len(cfg.Domains), 1)), cfg.Domains...)
+	defer fresh.Stop()
 	if len(cfg.Domains) != 0 {
 		wg.Go(func() {
-			fresh := refresher.New(*interval, cachedClient, *timeout*time.Duration(len(cfg.Domains)), cfg.Domains...)
-			defer fresh.Stop()
 			fresh.Run(ctx)
 		})
 
-		domainCollector := collector.NewDomainCollector(cachedClient, *timeout*time.Duration(len(cfg.Domains)), cfg.Domains...)
+		domainCollector := collector.NewDomainCollector(ctx, cachedClient, *timeout, *legacyDays, cfg.Domains...)
+		if *snapshot {
+			domainCollector = collector.NewSnapshotCollector(fresh, *legacyDays, cfg.Domains...)
+		}
 		prometheus.DefaultRegisterer.MustRegister(domainCollector)
 	}
 
+	if cfg.Notifications.Enabled() && len(cfg.Domains) != 0 {
+		notifier, err := notifier.New(cfg.Notifications, fresh, cfg.Domains...)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error to create notifier")
+		}
+		wg.Go(func() {
+			notifier.Run(ctx)
+		})
+	}
+
 	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/probe", probeHandler(cachedClient))
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = fmt.Fprintf(
-			w, `
-			<html>
-			<head><title>Domain Exporter</title></head>
-			<body>
-				<h1>Domain Exporter</h1>
-				<p><a href="%[1]s/metrics">Metrics</a></p>
-				<p><a href="%[1]s/probe?target=google.com">probe google.com</a></p>
-			</body>
-			</html>
-			`, urlPrefix,
-		)
-	})
+	http.HandleFunc("/probe", probeHandler(probers, cfg.Groups, *legacyDays))
+	http.Handle("/api/", api.New(cachedClient, api.WithToken(*apiToken), api.WithRefresher(fresh), api.WithHistory(hist)))
+	http.Handle("/", api.NewStatusPage(cachedClient, fresh, urlPrefix))
 
 	if err := runServerWithGracefullyShutdown(wg); err != nil {
 		log.Fatal().Err(err).Msg("error starting server")
@@ -106,6 +166,32 @@ func main() {
 	log.Info().Msg("domain exporter is finished")
 }
 
+// generateRules prints the alerting rules for the config.
+func generateRules() {
+	cfg, err := safeconfig.New(*configFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error to create config")
+	}
+	rules, err := generate.Rules(cfg, generate.RulesFormat(*rulesFormat), *rulesLabels)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error to generate rules")
+	}
+	if _, err := os.Stdout.Write(rules); err != nil {
+		log.Fatal().Err(err).Msg("error to write rules")
+	}
+}
+
+// generateDashboard prints the Grafana dashboard.
+func generateDashboard() {
+	dashboard, err := generate.Dashboard()
+	if err != nil {
+		log.Fatal().Err(err).Msg("error to generate dashboard")
+	}
+	if _, err := os.Stdout.Write(dashboard); err != nil {
+		log.Fatal().Err(err).Msg("error to write dashboard")
+	}
+}
+
 func runServerWithGracefullyShutdown(wg *sync.WaitGroup) error {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGTERM)
@@ -133,20 +219,187 @@ func runServerWithGracefullyShutdown(wg *sync.WaitGroup) error {
 	return nil
 }
 
-func probeHandler(cli client.Client) http.HandlerFunc {
+// prober holds the clients used by a probe module.
+type prober struct {
+	cached  client.Client
+	live    client.Client
+	timeout time.Duration
+}
+
+// newProbers returns the cached client of the default module and the probers
+// of all modules, with their clients built by newClient. The modules share
+// the cache, keyed by the options of each one.
+func newProbers(
+	cache *cache.Cache,
+	defaults safeconfig.Module,
+	modules map[string]safeconfig.Module,
+	newClient func(safeconfig.Module) (client.Client, string),
+) (*client.CachedClient, map[string]prober) {
+	cli, options := newClient(defaults)
+	cachedClient := client.NewCachedClient(cli, cache, options)
+
+	probers := map[string]prober{
+		"": {cached: cachedClient, live: cli, timeout: defaults.Timeout},
+	}
+	for name, module := range modules {
+		module = module.WithDefaults(defaults)
+		cli, options := newClient(module)
+		probers[name] = prober{
+			cached:  cachedClient.WithClient(cli, options),
+			live:    cli,
+			timeout: module.Timeout,
+		}
+	}
+	return cachedClient, probers
+}
+
+// newClient returns the client for the given module, along with a
+// description of its options.
+func newClient(module safeconfig.Module, metrics *client.Metrics, whoisProfiles whois.Profiles) (client.Client, string) {
+	whoisOpts := []whois.Option{
+		whois.WithProfiles(whoisProfiles),
+		whois.WithExpiryPolicy(whois.ExpiryPolicy(module.ExpiryPolicy)),
+	}
+	if *module.Referral {
+		whoisOpts = append(whoisOpts, whois.WithReferral(module.MaxReferrals))
+	}
+
+	var backends []client.Client
+	for _, protocol := range module.Protocols {
+		switch protocol {
+		case "rdap":
+			backends = append(backends, metrics.Instrument("rdap", rdap.NewClient()))
+		case "whois":
+			backends = append(backends, metrics.Instrument("whois", whois.NewClient(whoisOpts...)))
+		}
+	}
+
+	options := fmt.Sprintf(
+		"protocols=%s,strategy=%s,policy=%s,referral=%t,max_referrals=%d,profiles=%s",
+		strings.Join(module.Protocols, "+"), module.Strategy, module.ExpiryPolicy,
+		*module.Referral, module.MaxReferrals, *profiles,
+	)
+	return client.NewStrategyClient(client.Strategy(module.Strategy), backends...), options
+}
+
+func probeHandler(probers map[string]prober, groups map[string]safeconfig.Group, legacyExpiryDays bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := r.URL.Query()
-		target := strings.TrimPrefix(params.Get("target"), "www.")
-		host := params.Get("host")
-		if target == "" {
-			log.Error().Msg("target parameter missing")
-			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		domains, err := probeDomains(params, groups)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid probe")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		module := params.Get("module")
+		prober, ok := probers[module]
+		if !ok {
+			log.Error().Msgf("unknown module %q", module)
+			http.Error(w, fmt.Sprintf("unknown module %q", module), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := probeContext(r)
+		defer cancel()
+
+		if params.Get("debug") == "true" {
+			debugProbe(ctx, w, prober, legacyExpiryDays, domains...)
 			return
 		}
 
 		registry := prometheus.NewRegistry()
-		registry.MustRegister(collector.NewDomainCollector(cli, *timeout, safeconfig.Domain{Name: target, Host: host}))
+		registry.MustRegister(collector.NewDomainCollector(ctx, prober.cached, prober.timeout, legacyExpiryDays, domains...))
 
 		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
 	}
 }
+
+// probeContext returns the context of a probe, which is canceled if the
+// client goes away and ends before the Prometheus scrape times out.
+func probeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return context.WithCancel(r.Context())
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		log.Warn().Msgf("invalid scrape timeout %q", header)
+		return context.WithCancel(r.Context())
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > *timeoutOffset {
+		timeout -= *timeoutOffset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// probeDomains returns the domains of the target and group parameters, once
+// per name. A group entry replaces a target of the same name, as it carries
+// the configured host and thresholds.
+func probeDomains(params url.Values, groups map[string]safeconfig.Group) ([]safeconfig.Domain, error) {
+	var domains []safeconfig.Domain
+	index := map[string]int{}
+	grouped := map[string]bool{}
+	add := func(domain safeconfig.Domain, group bool) {
+		i, ok := index[domain.Name]
+		switch {
+		case !ok:
+			index[domain.Name] = len(domains)
+			domains = append(domains, domain)
+		case group && !grouped[domain.Name]:
+			domains[i] = domain
+		}
+		grouped[domain.Name] = grouped[domain.Name] || group
+	}
+
+	for _, target := range params["target"] {
+		if target = strings.TrimPrefix(target, "www."); target != "" {
+			add(safeconfig.Domain{Name: target, Host: params.Get("host")}, false)
+		}
+	}
+	for _, name := range params["group"] {
+		group, ok := groups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %q", name)
+		}
+		for _, domain := range group.Domains {
+			add(domain, true)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("target parameter is missing")
+	}
+	return domains, nil
+}
+
+// debugProbe probes the domains bypassing the cache, writing what each
+// backend did for each domain followed by the resulting metrics.
+func debugProbe(ctx context.Context, w http.ResponseWriter, prober prober, legacyExpiryDays bool, domains ...safeconfig.Domain) {
+	traces := make(map[string]*client.Trace, len(domains))
+	for _, domain := range domains {
+		traces[domain.Name] = &client.Trace{}
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector.NewDomainCollector(ctx, client.NewTracedClient(prober.live, traces), prober.timeout, legacyExpiryDays, domains...))
+	families, err := registry.Gather()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to gather metrics")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	written := map[string]bool{}
+	for _, domain := range domains {
+		if written[domain.Name] {
+			continue
+		}
+		written[domain.Name] = true
+		_, _ = fmt.Fprintf(w, "Trace for probe of %s:\n%s\n", domain.Name, traces[domain.Name])
+	}
+	_, _ = fmt.Fprint(w, "Metrics that would have been returned:\n")
+	encoder := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			log.Error().Err(err).Msg("failed to write metrics")
+		}
+	}
+}
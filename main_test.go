@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/domain_exporter/internal/client"
+	"github.com/caarlos0/domain_exporter/internal/safeconfig"
+	"github.com/caarlos0/domain_exporter/internal/whois"
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	expiry time.Time
+}
+
+func (f fakeClient) Lookup(ctx context.Context, domain string, _ string) (client.Result, error) {
+	client.TraceFrom(ctx).Add("fake", "request", domain)
+	return client.Result{Expiry: f.expiry}, nil
+}
+
+func newTestProbers(t *testing.T) map[string]prober {
+	t.Helper()
+	// each module gets a different expiry, depending on its protocols.
+	expiries := map[string]time.Time{
+		"rdap+whois": time.Unix(1900000000, 0),
+		"rdap":       time.Unix(1910000000, 0),
+		"whois":      time.Unix(1920000000, 0),
+	}
+	defaults := safeconfig.Module{Protocols: []string{"rdap", "whois"}, Timeout: time.Second}
+	modules := map[string]safeconfig.Module{
+		"fast_rdap":  {Protocols: []string{"rdap"}},
+		"slow_whois": {Protocols: []string{"whois"}, Timeout: time.Minute},
+	}
+	_, probers := newProbers(cache.New(time.Hour, time.Hour), defaults, modules, func(module safeconfig.Module) (client.Client, string) {
+		protocols := strings.Join(module.Protocols, "+")
+		return fakeClient{expiry: expiries[protocols]}, "protocols=" + protocols
+	})
+	return probers
+}
+
+func probe(t *testing.T, handler http.HandlerFunc, query string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/probe?"+query, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestNewProbers(t *testing.T) {
+	probers := newTestProbers(t)
+	require.Len(t, probers, 3)
+	require.Equal(t, time.Second, probers[""].timeout)
+	require.Equal(t, time.Second, probers["fast_rdap"].timeout)
+	require.Equal(t, time.Minute, probers["slow_whois"].timeout)
+}
+
+func TestProbeModules(t *testing.T) {
+	handler := probeHandler(newTestProbers(t), nil, false)
+
+	// the modules share the cache, so each one must get its own results for
+	// the same target.
+	for _, query := range []string{"", "&module=fast_rdap", "&module=slow_whois", ""} {
+		status, body := probe(t, handler, "target=foo.com"+query)
+		require.Equal(t, http.StatusOK, status)
+		expected := map[string]string{
+			"":                   `domain_expiry_timestamp_seconds{domain="foo.com",source="effective"} 1.9e+09`,
+			"&module=fast_rdap":  `domain_expiry_timestamp_seconds{domain="foo.com",source="effective"} 1.91e+09`,
+			"&module=slow_whois": `domain_expiry_timestamp_seconds{domain="foo.com",source="effective"} 1.92e+09`,
+		}[query]
+		require.Contains(t, body, expected, query)
+	}
+
+	status, body := probe(t, handler, "target=foo.com&module=unknown")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "unknown module \"unknown\"\n", body)
+}
+
+func TestNewClient(t *testing.T) {
+	referral := true
+	module := safeconfig.Module{
+		Protocols:    []string{"whois"},
+		Strategy:     "sequential",
+		ExpiryPolicy: "registrar",
+		Referral:     &referral,
+		MaxReferrals: 5,
+	}
+	_, options := newClient(module, client.NewMetrics(), whois.Profiles{})
+	require.Equal(t, "protocols=whois,strategy=sequential,policy=registrar,referral=true,max_referrals=5,profiles=", options)
+
+	module.Protocols = []string{"rdap", "whois"}
+	_, other := newClient(module, client.NewMetrics(), whois.Profiles{})
+	require.NotEqual(t, options, other)
+}
+
+func TestProbeDomains(t *testing.T) {
+	groups := map[string]safeconfig.Group{
+		"payments": {Domains: []safeconfig.Domain{{Name: "pay.com"}, {Name: "checkout.com", Host: "whois.checkout"}}},
+		"blogs":    {Domains: []safeconfig.Domain{{Name: "blog.com"}, {Name: "pay.com"}}},
+	}
+	for _, tt := range []struct {
+		name    string
+		query   string
+		domains []safeconfig.Domain
+		err     string
+	}{
+		{
+			name:    "target",
+			query:   "target=www.foo.com&host=whois.foo",
+			domains: []safeconfig.Domain{{Name: "foo.com", Host: "whois.foo"}},
+		},
+		{
+			name:    "targets",
+			query:   "target=foo.com&target=bar.com&target=foo.com&target=",
+			domains: []safeconfig.Domain{{Name: "foo.com"}, {Name: "bar.com"}},
+		},
+		{
+			name:    "groups",
+			query:   "group=payments&group=blogs",
+			domains: []safeconfig.Domain{{Name: "pay.com"}, {Name: "checkout.com", Host: "whois.checkout"}, {Name: "blog.com"}},
+		},
+		{
+			name:    "targets and group",
+			query:   "target=pay.com&target=checkout.com&group=payments",
+			domains: []safeconfig.Domain{{Name: "pay.com"}, {Name: "checkout.com", Host: "whois.checkout"}},
+		},
+		{
+			name:    "target with host and group",
+			query:   "target=checkout.com&host=whois.other&group=blogs&group=payments",
+			domains: []safeconfig.Domain{{Name: "checkout.com", Host: "whois.checkout"}, {Name: "blog.com"}, {Name: "pay.com"}},
+		},
+		{
+			name:  "unknown group",
+			query: "target=foo.com&group=unknown",
+			err:   `unknown group "unknown"`,
+		},
+		{
+			name:  "missing",
+			query: "target=",
+			err:   "target parameter is missing",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			domains, err := probeDomains(params, groups)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.domains, domains)
+		})
+	}
+
+	status, body := probe(t, probeHandler(newTestProbers(t), groups, false), "group=unknown")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "unknown group \"unknown\"\n", body)
+
+	status, body = probe(t, probeHandler(newTestProbers(t), groups, false), "target=checkout.com&group=payments")
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, 1, strings.Count(body, `domain_probe_success{domain="checkout.com"} 1`))
+}
+
+func TestDebugProbe(t *testing.T) {
+	status, body := probe(t, probeHandler(newTestProbers(t), nil, false), "target=foo.com&target=bar.com&debug=true")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, "Trace for probe of foo.com:\n[fake] request: foo.com\n\n")
+	require.Contains(t, body, "Trace for probe of bar.com:\n[fake] request: bar.com\n\n")
+	require.Contains(t, body, `domain_probe_success{domain="bar.com"} 1`)
+}
+
+func TestProbeContext(t *testing.T) {
+	offset := *timeoutOffset
+	*timeoutOffset = 500 * time.Millisecond
+	t.Cleanup(func() { *timeoutOffset = offset })
+
+	for _, tt := range []struct {
+		name    string
+		header  string
+		timeout time.Duration // zero means no deadline
+	}{
+		{name: "no header"},
+		{name: "offset subtracted", header: "10", timeout: 9500 * time.Millisecond},
+		{name: "fractional", header: "2.5", timeout: 2 * time.Second},
+		{name: "smaller than offset", header: "0.3", timeout: 300 * time.Millisecond},
+		{name: "equal to offset", header: "0.5", timeout: 500 * time.Millisecond},
+		{name: "invalid", header: "soon"},
+		{name: "zero", header: "0"},
+		{name: "negative", header: "-1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/probe?target=foo.com", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+			}
+			start := time.Now()
+			ctx, cancel := probeContext(req)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if tt.timeout == 0 {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.WithinDuration(t, start.Add(tt.timeout), deadline, 50*time.Millisecond)
+		})
+	}
+
+	t.Run("client gone", func(t *testing.T) {
+		reqCtx, cancelReq := context.WithCancel(context.Background())
+		req := httptest.NewRequestWithContext(reqCtx, http.MethodGet, "/probe?target=foo.com", nil)
+		req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+		ctx, cancel := probeContext(req)
+		defer cancel()
+
+		cancelReq()
+		<-ctx.Done()
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+}